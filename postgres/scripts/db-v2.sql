@@ -7,16 +7,20 @@ GRANT ALL PRIVILEGES ON DATABASE reservations TO program;
 CREATE DATABASE loyalties;
 GRANT ALL PRIVILEGES ON DATABASE loyalties TO program;
 
+CREATE DATABASE gateway;
+GRANT ALL PRIVILEGES ON DATABASE gateway TO program;
+
 \connect payments
 
 CREATE TABLE payments
 (
-    id          SERIAL PRIMARY KEY,
-    payment_uid uuid        NOT NULL,
-    username    VARCHAR(80) NOT NULL,
-    status      VARCHAR(20) NOT NULL
+    id            SERIAL PRIMARY KEY,
+    payment_uid   uuid        NOT NULL,
+    username      VARCHAR(80) NOT NULL,
+    status        VARCHAR(20) NOT NULL
         CHECK (status IN ('PAID', 'CANCELED')),
-    price       INT         NOT NULL
+    price         INT         NOT NULL,
+    operation_key VARCHAR(255) UNIQUE
 );
 
 ALTER TABLE payments OWNER TO program;
@@ -28,22 +32,41 @@ CREATE TABLE loyalties
     id                SERIAL PRIMARY KEY,
     username          VARCHAR(80) NOT NULL UNIQUE,
     reservation_count INT         NOT NULL DEFAULT 0,
+    -- reservations counted before loyalty_events was kept; the events are
+    -- counted on top of them
+    legacy_count      INT         NOT NULL DEFAULT 0,
     status            VARCHAR(80) NOT NULL DEFAULT 'BRONZE'
         CHECK (status IN ('BRONZE', 'SILVER', 'GOLD')),
     discount          INT         NOT NULL
 );
 
-INSERT INTO loyalties (id, username, reservation_count, status, discount)
+INSERT INTO loyalties (id, username, reservation_count, legacy_count, status, discount)
 VALUES (
     1,
     'Test Max',
     25,
+    25,
     'GOLD',
     10
 );
 
+SELECT setval('loyalties_id_seq', (SELECT MAX(id) FROM loyalties));
+
+CREATE TABLE loyalty_events
+(
+    id              SERIAL PRIMARY KEY,
+    username        VARCHAR(80) NOT NULL,
+    reservation_uid uuid        NOT NULL,
+    event_type      VARCHAR(20) NOT NULL
+        CHECK (event_type IN ('INCREMENT', 'DECREMENT')),
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    UNIQUE (reservation_uid, event_type)
+);
+
+CREATE INDEX loyalty_events_username_idx ON loyalty_events (username);
 
 ALTER TABLE loyalties OWNER TO program;
+ALTER TABLE loyalty_events OWNER TO program;
 
 \connect reservations
 
@@ -71,6 +94,13 @@ VALUES (
     10000
 );
 
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX hotels_country_city_idx ON hotels (country, city);
+CREATE INDEX hotels_price_idx ON hotels (price);
+CREATE INDEX hotels_stars_idx ON hotels (stars);
+CREATE INDEX hotels_name_trgm_idx ON hotels USING gin (name gin_trgm_ops);
+
 CREATE TABLE reservations
 (
     id              SERIAL PRIMARY KEY,
@@ -84,5 +114,62 @@ CREATE TABLE reservations
     end_data        TIMESTAMP WITH TIME ZONE
 );
 
+CREATE INDEX reservations_username_start_idx ON reservations (username, start_date);
+
 ALTER TABLE hotels OWNER TO program;
-ALTER TABLE reservations OWNER TO program;
\ No newline at end of file
+ALTER TABLE reservations OWNER TO program;
+
+\connect gateway
+
+CREATE TABLE saga_tasks
+(
+    id          SERIAL PRIMARY KEY,
+    kind        VARCHAR(40) NOT NULL,
+    payload     JSONB       NOT NULL,
+    status      VARCHAR(20) NOT NULL DEFAULT 'PENDING'
+        CHECK (status IN ('PENDING', 'RUNNING', 'DONE', 'DEAD')),
+    attempts      INT         NOT NULL DEFAULT 0,
+    postponements INT         NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    last_error  TEXT,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX saga_tasks_pending_idx ON saga_tasks (next_run_at) WHERE status = 'PENDING';
+CREATE INDEX saga_tasks_dead_idx ON saga_tasks (updated_at) WHERE status = 'DEAD';
+
+CREATE TABLE reservation_requests
+(
+    id              SERIAL PRIMARY KEY,
+    request_uid     uuid UNIQUE NOT NULL,
+    username        VARCHAR(80) NOT NULL,
+    hotel_uid       uuid        NOT NULL,
+    start_date      VARCHAR(10) NOT NULL,
+    end_date        VARCHAR(10) NOT NULL,
+    status          VARCHAR(20) NOT NULL DEFAULT 'PENDING'
+        CHECK (status IN ('PENDING', 'COMPLETED', 'FAILED')),
+    reservation_uid uuid,
+    last_error      TEXT,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE idempotency_keys
+(
+    id              SERIAL PRIMARY KEY,
+    username        VARCHAR(80)  NOT NULL,
+    idem_key        VARCHAR(255) NOT NULL,
+    request_hash    VARCHAR(64)  NOT NULL,
+    payment_key     VARCHAR(64),
+    payment_uid     uuid,
+    reservation_uid uuid,
+    response        JSONB,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    UNIQUE (username, idem_key)
+);
+
+ALTER TABLE saga_tasks OWNER TO program;
+ALTER TABLE reservation_requests OWNER TO program;
+ALTER TABLE idempotency_keys OWNER TO program;
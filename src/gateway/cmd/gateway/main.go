@@ -1,29 +1,90 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"log"
 	"net/http"
-	"time"
 
+	_ "github.com/lib/pq"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/cache"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/config"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/health"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/httpserver"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/repository"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/retry"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/service"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+
+	db, err := sql.Open("postgres", cfg.DBDSN)
+	if err != nil {
+		log.Fatalf("db open error: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatalf("db ping error: %v", err)
+	}
+
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy())
+	setBreakerPolicies(breakers, clients.ReservationService, cfg.Reservation)
+	setBreakerPolicies(breakers, clients.PaymentService, cfg.Payment)
+	setBreakerPolicies(breakers, clients.LoyaltyService, cfg.Loyalty)
+	breakers.OnStateChange(func(e circuitbreaker.Event) {
+		log.Printf("circuit breaker %s: %s -> %s (failure rate %.2f)", e.Name, e.From, e.To, e.FailureRate)
+	})
 
-	loyaltyBreaker := circuitbreaker.New(10, 0.5, 5*time.Second)
-	reservationBreaker := circuitbreaker.New(10, 0.5, 5*time.Second)
-	paymentBreaker := circuitbreaker.New(10, 0.5, 5*time.Second)
+	resClient := clients.NewReservationClient(cfg.Reservation.URL, timeouts(cfg.Reservation), retries(cfg.Reservation), breakers,
+		bulkhead.New(clients.ReservationService, cfg.Reservation.BulkheadLimit, cfg.Reservation.BulkheadWait))
+	payClient := clients.NewPaymentClient(cfg.Payment.URL, timeouts(cfg.Payment), retries(cfg.Payment), breakers,
+		bulkhead.New(clients.PaymentService, cfg.Payment.BulkheadLimit, cfg.Payment.BulkheadWait))
+	loyalClient := clients.NewLoyaltyClient(cfg.Loyalty.URL, timeouts(cfg.Loyalty), retries(cfg.Loyalty), breakers,
+		bulkhead.New(clients.LoyaltyService, cfg.Loyalty.BulkheadLimit, cfg.Loyalty.BulkheadWait))
 
-	resClient := clients.NewReservationClient(cfg.ReservationURL, reservationBreaker)
-	payClient := clients.NewPaymentClient(cfg.PaymentURL, paymentBreaker)
-	loyalClient := clients.NewLoyaltyClient(cfg.LoyaltyURL, loyaltyBreaker)
+	prober := health.NewProber(cfg.HealthCheckInterval, cfg.HealthCheckTimeout)
+	prober.Add(clients.ReservationService, resClient.CheckHealth)
+	prober.Add(clients.PaymentService, payClient.CheckHealth)
+	prober.Add(clients.LoyaltyService, loyalClient.CheckHealth)
+	prober.OnResult(func(r health.Result) {
+		// A failed probe counts against every closed breaker of the service,
+		// so the circuit opens even when no calls are being made. Successful
+		// probes are left out, as they would dilute the failures of real calls.
+		if r.Status != health.Down {
+			return
+		}
+		for _, cb := range breakers.Service(r.Name) {
+			cb.Record(false)
+		}
+	})
+	go prober.Run(context.Background())
 
-	svc := service.NewGatewayService(resClient, payClient, loyalClient)
+	taskRepo := repository.NewTaskRepository(db)
+	requestRepo := repository.NewRequestRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+
+	retryPolicy := retry.Policy{
+		MaxAttempts: cfg.SagaMaxAttempts,
+		BaseDelay:   cfg.SagaBackoffBase,
+		MaxDelay:    cfg.SagaBackoffMax,
+	}
+
+	cachePolicy := cache.Policy{
+		Capacity:        cfg.CacheSize,
+		MaxStale:        cfg.CacheMaxStale,
+		RefreshInterval: cfg.CacheRefreshInterval,
+	}
+
+	svc := service.NewGatewayService(resClient, payClient, loyalClient, breakers, prober, taskRepo, requestRepo, idempotencyRepo, retryPolicy, cachePolicy, cfg.EnrichConcurrency)
 	router := httpserver.NewRouter(svc)
 
 	log.Printf("gateway listening on %s", cfg.Addr())
@@ -31,3 +92,53 @@ func main() {
 		log.Fatalf("server stopped: %v", err)
 	}
 }
+
+func timeouts(d config.Downstream) clients.Timeouts {
+	return clients.Timeouts{
+		Default:      d.HTTPTimeout,
+		PerOperation: d.OperationTimeouts,
+	}
+}
+
+// retries lets a GET be tried RetryAttempts more times, with the backoff
+// never growing beyond the timeout of a single call.
+func retries(d config.Downstream) clients.Retries {
+	return clients.Retries{
+		Policy: retry.Policy{
+			MaxAttempts: d.RetryAttempts + 1,
+			BaseDelay:   d.RetryBackoff,
+			MaxDelay:    d.HTTPTimeout,
+		},
+		Budget:          d.RetryBudget,
+		HedgePercentile: d.HedgePercentile,
+	}
+}
+
+func breakerPolicy(b config.Breaker) circuitbreaker.Policy {
+	return circuitbreaker.Policy{
+		WindowSize:       b.Window,
+		BucketWidth:      b.BucketWidth,
+		MinRequests:      b.MinRequests,
+		FailureThreshold: b.Threshold,
+		OpenTimeout:      b.OpenTimeout,
+		HalfOpenProbes:   b.Probes,
+	}
+}
+
+// setBreakerPolicies applies the read policy of a downstream to all its
+// operations and the write policy to the ones that change its state. It then
+// creates the breaker of every operation, so that all of them are listed
+// from the start rather than once they have seen traffic.
+func setBreakerPolicies(breakers *circuitbreaker.Registry, service string, d config.Downstream) {
+	breakers.SetServicePolicy(service, breakerPolicy(d.Breaker))
+	for _, op := range clients.WriteOperations[service] {
+		breakers.SetPolicy(service, op, breakerPolicy(d.WriteBreaker))
+	}
+
+	for _, op := range clients.ReadOperations[service] {
+		breakers.Get(service, op)
+	}
+	for _, op := range clients.WriteOperations[service] {
+		breakers.Get(service, op)
+	}
+}
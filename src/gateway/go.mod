@@ -1,3 +1,8 @@
 module github.com/gazizov-ai/lab2-rsoi/src/gateway
 
 go 1.22
+
+require (
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
+)
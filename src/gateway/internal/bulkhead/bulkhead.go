@@ -0,0 +1,89 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var ErrFull = errors.New("too many concurrent requests")
+
+// Snapshot is a point-in-time view of a bulkhead for reporting. Saturation
+// is the share of the limit currently in use.
+type Snapshot struct {
+	Name       string  `json:"name"`
+	Limit      int     `json:"limit"`
+	InFlight   int     `json:"inFlight"`
+	Waiting    int64   `json:"waiting"`
+	Rejected   int64   `json:"rejected"`
+	Saturation float64 `json:"saturation"`
+}
+
+// Bulkhead limits the number of concurrent calls to one downstream. A call
+// over the limit waits up to maxWait for a free slot and is then rejected.
+type Bulkhead struct {
+	name    string
+	slots   chan struct{}
+	maxWait time.Duration
+
+	waiting  atomic.Int64
+	rejected atomic.Int64
+}
+
+func New(name string, limit int, maxWait time.Duration) *Bulkhead {
+	return &Bulkhead{
+		name:    name,
+		slots:   make(chan struct{}, limit),
+		maxWait: maxWait,
+	}
+}
+
+// Acquire takes a slot. It fails with ErrFull when no slot frees up within
+// maxWait, or with the context error when ctx ends first. Every successful
+// Acquire must be followed by Release.
+func (b *Bulkhead) Acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if b.maxWait <= 0 {
+		b.rejected.Add(1)
+		return fmt.Errorf("%s: %w", b.name, ErrFull)
+	}
+
+	b.waiting.Add(1)
+	defer b.waiting.Add(-1)
+
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		b.rejected.Add(1)
+		return fmt.Errorf("%s: %w", b.name, ErrFull)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Bulkhead) Release() {
+	<-b.slots
+}
+
+func (b *Bulkhead) Snapshot() Snapshot {
+	inFlight := len(b.slots)
+	return Snapshot{
+		Name:       b.name,
+		Limit:      cap(b.slots),
+		InFlight:   inFlight,
+		Waiting:    b.waiting.Load(),
+		Rejected:   b.rejected.Load(),
+		Saturation: float64(inFlight) / float64(cap(b.slots)),
+	}
+}
@@ -0,0 +1,37 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBulkhead_RejectsAfterWait(t *testing.T) {
+	b := New("payment-service", 1, 10*time.Millisecond)
+
+	if err := b.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Acquire(context.Background()); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+
+	snap := b.Snapshot()
+	if snap.InFlight != 1 || snap.Rejected != 1 || snap.Saturation != 1 {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+}
+
+func TestBulkhead_WaiterGetsReleasedSlot(t *testing.T) {
+	b := New("loyalty-service", 1, time.Second)
+
+	if err := b.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.AfterFunc(10*time.Millisecond, b.Release)
+
+	if err := b.Acquire(context.Background()); err != nil {
+		t.Fatalf("expected the waiter to get the released slot, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Policy bounds a cache. Capacity is the number of entries kept, MaxStale the
+// age after which an entry is no longer served, and RefreshInterval the pause
+// between background refresh attempts.
+type Policy struct {
+	Capacity        int
+	MaxStale        time.Duration
+	RefreshInterval time.Duration
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		Capacity:        256,
+		MaxStale:        1 * time.Hour,
+		RefreshInterval: 5 * time.Second,
+	}
+}
+
+type entry[V any] struct {
+	key      string
+	value    V
+	storedAt time.Time
+}
+
+// Cache keeps the last successful responses of a downstream read, evicting
+// the least recently used entry once Capacity is reached.
+type Cache[V any] struct {
+	mu sync.Mutex
+
+	policy     Policy
+	order      *list.List
+	items      map[string]*list.Element
+	refreshing map[string]bool
+}
+
+func New[V any](p Policy) *Cache[V] {
+	return &Cache[V]{
+		policy:     p,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		refreshing: make(map[string]bool),
+	}
+}
+
+func (c *Cache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value = &entry[V]{key: key, value: value, storedAt: time.Now()}
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[V]{key: key, value: value, storedAt: time.Now()})
+	for c.policy.Capacity > 0 && c.order.Len() > c.policy.Capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry[V]).key)
+	}
+}
+
+// Get returns the entry stored under key and the time it was stored. Entries
+// older than MaxStale are dropped and not returned.
+func (c *Cache[V]) Get(key string) (V, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, time.Time{}, false
+	}
+
+	e := el.Value.(*entry[V])
+	if c.policy.MaxStale > 0 && time.Since(e.storedAt) > c.policy.MaxStale {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return zero, time.Time{}, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.value, e.storedAt, true
+}
+
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// Revalidate refreshes key in the background by calling fetch every
+// RefreshInterval until it succeeds or the entry is gone. Only one refresh
+// runs per key at a time.
+func (c *Cache[V]) Revalidate(key string, fetch func() (V, error)) {
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+
+		for {
+			time.Sleep(c.policy.RefreshInterval)
+
+			if _, _, ok := c.Get(key); !ok {
+				return
+			}
+			if v, err := fetch(); err == nil {
+				c.Set(key, v)
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[int](Policy{Capacity: 2, MaxStale: time.Hour})
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	if v, _, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a to stay cached, got %v %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("len = %d, want 2", c.Len())
+	}
+}
+
+func TestCache_DropsEntriesPastMaxStale(t *testing.T) {
+	c := New[int](Policy{Capacity: 2, MaxStale: time.Millisecond})
+
+	c.Set("a", 1)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, _, ok := c.Get("a"); ok {
+		t.Fatalf("expected entry past MaxStale not to be served")
+	}
+}
+
+func TestCache_RevalidateRetriesUntilSuccess(t *testing.T) {
+	c := New[int](Policy{Capacity: 2, MaxStale: time.Hour, RefreshInterval: time.Millisecond})
+	c.Set("a", 1)
+
+	calls := 0
+	done := make(chan struct{})
+	c.Revalidate("a", func() (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, errors.New("unavailable")
+		}
+		close(done)
+		return 2, nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("refresh did not succeed")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, _, _ := c.Get("a"); v == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected refreshed value to be stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
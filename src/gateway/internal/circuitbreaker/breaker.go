@@ -1,6 +1,7 @@
 package circuitbreaker
 
 import (
+	"sort"
 	"sync"
 	"time"
 )
@@ -13,90 +14,396 @@ const (
 	HalfOpen
 )
 
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "CLOSED"
+	case Open:
+		return "OPEN"
+	case HalfOpen:
+		return "HALF_OPEN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Event describes a state transition of a breaker.
+type Event struct {
+	Name        string    `json:"name"`
+	From        State     `json:"from"`
+	To          State     `json:"to"`
+	FailureRate float64   `json:"failureRate"`
+	At          time.Time `json:"at"`
+}
+
+// Snapshot is a point-in-time view of a breaker for reporting. In count mode
+// Window lists the recorded outcomes oldest first, with true marking a
+// failure; in time mode Buckets lists the live buckets oldest first.
+type Snapshot struct {
+	Name           string           `json:"name"`
+	State          State            `json:"state"`
+	FailureRate    float64          `json:"failureRate"`
+	Failures       int              `json:"failures"`
+	Successes      int              `json:"successes"`
+	MinRequests    int              `json:"minRequests"`
+	HalfOpenProbes int              `json:"halfOpenProbes"`
+	ProbesAdmitted int              `json:"probesAdmitted,omitempty"`
+	WindowSize     int              `json:"windowSize"`
+	BucketWidthMs  int64            `json:"bucketWidthMs,omitempty"`
+	Window         []bool           `json:"window,omitempty"`
+	Buckets        []BucketSnapshot `json:"buckets,omitempty"`
+	LastTransition time.Time        `json:"lastTransition"`
+}
+
+type BucketSnapshot struct {
+	Start     time.Time `json:"start"`
+	Failures  int       `json:"failures"`
+	Successes int       `json:"successes"`
+}
+
+type bucket struct {
+	start     time.Time
+	failures  int
+	successes int
+}
+
 type CircuitBreaker struct {
 	mu sync.Mutex
 
-	state State
+	name   string
+	state  State
+	policy Policy
 
+	// Count mode: a ring of the last outcomes, true marking a failure.
+	// filled grows until the ring is full so that empty slots are not
+	// mistaken for outcomes.
+	window    []bool
+	index     int
+	filled    int
 	failures  int
 	successes int
 
-	window []bool
-	index  int
+	// Time mode: a ring of buckets addressed by their start time.
+	buckets []bucket
 
-	windowSize       int
-	failureThreshold float64
-	openTimeout      time.Duration
-	lastStateChange  time.Time
+	// generation changes on every transition; see Permit.
+	generation     uint64
+	probesAdmitted int
+	probeSuccesses int
+
+	lastStateChange time.Time
+
+	hooks []func(Event)
+	now   func() time.Time
 }
 
-func New(windowSize int, threshold float64, timeout time.Duration) *CircuitBreaker {
-	return &CircuitBreaker{
-		state:            Closed,
-		window:           make([]bool, windowSize),
-		windowSize:       windowSize,
-		failureThreshold: threshold,
-		openTimeout:      timeout,
-		lastStateChange:  time.Now(),
+func New(p Policy) *CircuitBreaker {
+	if p.HalfOpenProbes < 1 {
+		p.HalfOpenProbes = 1
+	}
+	cb := &CircuitBreaker{
+		state:           Closed,
+		policy:          p,
+		lastStateChange: time.Now(),
+		now:             time.Now,
 	}
+	if p.BucketWidth > 0 {
+		cb.buckets = make([]bucket, p.WindowSize)
+	} else {
+		cb.window = make([]bool, p.WindowSize)
+	}
+	return cb
 }
 
-func (cb *CircuitBreaker) Allow() bool {
+// OnStateChange registers a hook called after every state transition. Hooks
+// run outside the breaker lock and may inspect the breaker.
+func (cb *CircuitBreaker) OnStateChange(fn func(Event)) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
+	cb.hooks = append(cb.hooks, fn)
+}
+
+// Permit is handed out by Acquire for one call. Its outcome is recorded with
+// Record and only counts while the breaker is still in the state the permit
+// was issued in.
+type Permit struct {
+	cb         *CircuitBreaker
+	generation uint64
+	probe      bool
+}
+
+func (p Permit) Record(success bool) {
+	p.cb.record(p.generation, p.probe, success)
+}
+
+// Release gives the permit back without an outcome, for a call that was
+// abandoned by the caller and says nothing about the downstream.
+func (p Permit) Release() {
+	p.cb.mu.Lock()
+	defer p.cb.mu.Unlock()
+
+	if p.probe && p.generation == p.cb.generation && p.cb.probesAdmitted > 0 {
+		p.cb.probesAdmitted--
+	}
+}
+
+// Acquire asks for permission to make a call. While HalfOpen only
+// HalfOpenProbes calls are admitted, and their outcomes alone decide whether
+// the breaker closes or opens again.
+func (cb *CircuitBreaker) Acquire() (Permit, bool) {
+	cb.mu.Lock()
+
+	now := cb.now()
+	var ev *Event
 	if cb.state == Open {
-		if time.Since(cb.lastStateChange) > cb.openTimeout {
-			cb.state = HalfOpen
-			cb.failures = 0
-			cb.successes = 0
+		if now.Sub(cb.lastStateChange) <= cb.policy.OpenTimeout {
+			cb.mu.Unlock()
+			return Permit{}, false
+		}
+		ev = cb.setState(HalfOpen, now)
+	}
+
+	permit := Permit{cb: cb, generation: cb.generation}
+	ok := true
+	if cb.state == HalfOpen {
+		// A probe that never reports back would keep the breaker half open
+		// forever, so a round that stays undecided past the open timeout
+		// is abandoned and a new one started.
+		if cb.probesAdmitted >= cb.policy.HalfOpenProbes && now.Sub(cb.lastStateChange) > cb.policy.OpenTimeout {
+			cb.generation++
+			cb.lastStateChange = now
+			cb.probesAdmitted = 0
+			cb.probeSuccesses = 0
+			permit.generation = cb.generation
+		}
+		if cb.probesAdmitted < cb.policy.HalfOpenProbes {
+			cb.probesAdmitted++
+			permit.probe = true
 		} else {
-			return false
+			ok = false
 		}
 	}
 
-	return true
+	cb.mu.Unlock()
+	cb.emit(ev)
+	return permit, ok
 }
 
+// Record counts an outcome that was not obtained through a permit. It only
+// affects a closed breaker.
 func (cb *CircuitBreaker) Record(success bool) {
+	cb.mu.Lock()
+	generation := cb.generation
+	cb.mu.Unlock()
+
+	cb.record(generation, false, success)
+}
+
+func (cb *CircuitBreaker) record(generation uint64, probe, success bool) {
+	cb.mu.Lock()
+
+	if generation != cb.generation {
+		cb.mu.Unlock()
+		return
+	}
+
+	now := cb.now()
+	var ev *Event
+	switch cb.state {
+	case Closed:
+		if cb.buckets != nil {
+			b := cb.bucketAt(now)
+			if success {
+				b.successes++
+			} else {
+				b.failures++
+			}
+		} else {
+			cb.push(success)
+		}
+		ev = cb.evaluate(now)
+	case HalfOpen:
+		if !probe {
+			break
+		}
+		if !success {
+			ev = cb.setState(Open, now)
+			break
+		}
+		cb.probeSuccesses++
+		if cb.probeSuccesses >= cb.policy.HalfOpenProbes {
+			ev = cb.setState(Closed, now)
+		}
+	}
+
+	cb.mu.Unlock()
+	cb.emit(ev)
+}
+
+func (cb *CircuitBreaker) Snapshot() Snapshot {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	old := cb.window[cb.index]
-	if old {
-		cb.failures--
+	now := cb.now()
+	successes, failures := cb.counts(now)
+
+	snap := Snapshot{
+		Name:           cb.name,
+		State:          cb.state,
+		FailureRate:    cb.failureRate(now),
+		Failures:       failures,
+		Successes:      successes,
+		MinRequests:    cb.policy.MinRequests,
+		HalfOpenProbes: cb.policy.HalfOpenProbes,
+		ProbesAdmitted: cb.probesAdmitted,
+		WindowSize:     cb.policy.WindowSize,
+		BucketWidthMs:  cb.policy.BucketWidth.Milliseconds(),
+		LastTransition: cb.lastStateChange,
+	}
+
+	if cb.buckets != nil {
+		for _, b := range cb.liveBuckets(now) {
+			snap.Buckets = append(snap.Buckets, BucketSnapshot{Start: b.start, Failures: b.failures, Successes: b.successes})
+		}
+		return snap
+	}
+
+	n := len(cb.window)
+	for i := 0; i < cb.filled; i++ {
+		snap.Window = append(snap.Window, cb.window[(cb.index-cb.filled+i+n)%n])
+	}
+	return snap
+}
+
+func (cb *CircuitBreaker) push(success bool) {
+	if cb.filled == len(cb.window) {
+		if cb.window[cb.index] {
+			cb.failures--
+		} else {
+			cb.successes--
+		}
 	} else {
-		cb.successes--
+		cb.filled++
 	}
 
 	cb.window[cb.index] = !success
-
 	if success {
 		cb.successes++
 	} else {
 		cb.failures++
 	}
 
-	cb.index = (cb.index + 1) % cb.windowSize
+	cb.index = (cb.index + 1) % len(cb.window)
+}
 
-	cb.evaluate()
+// bucketAt returns the bucket covering now, clearing it first if it still
+// holds an older period.
+func (cb *CircuitBreaker) bucketAt(now time.Time) *bucket {
+	width := cb.policy.BucketWidth
+	start := now.Truncate(width)
+
+	b := &cb.buckets[int((start.UnixNano()/int64(width))%int64(len(cb.buckets)))]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	return b
 }
 
-func (cb *CircuitBreaker) evaluate() {
-	failureRate := float64(cb.failures) / float64(cb.windowSize)
+// liveBuckets returns the buckets inside the window ending at now, oldest
+// first.
+func (cb *CircuitBreaker) liveBuckets(now time.Time) []bucket {
+	width := cb.policy.BucketWidth
+	oldest := now.Truncate(width).Add(-width * time.Duration(len(cb.buckets)-1))
 
-	switch cb.state {
-	case Closed:
-		if failureRate >= cb.failureThreshold {
-			cb.state = Open
-			cb.lastStateChange = time.Now()
-		}
-	case HalfOpen:
-		if cb.failures > 0 {
-			cb.state = Open
-			cb.lastStateChange = time.Now()
-		} else if cb.successes > cb.windowSize/2 {
-			cb.state = Closed
+	live := make([]bucket, 0, len(cb.buckets))
+	for _, b := range cb.buckets {
+		if !b.start.Before(oldest) && !b.start.After(now) {
+			live = append(live, b)
 		}
 	}
+	sort.Slice(live, func(i, j int) bool { return live[i].start.Before(live[j].start) })
+	return live
+}
+
+func (cb *CircuitBreaker) counts(now time.Time) (successes, failures int) {
+	if cb.buckets == nil {
+		return cb.successes, cb.failures
+	}
+	for _, b := range cb.liveBuckets(now) {
+		successes += b.successes
+		failures += b.failures
+	}
+	return successes, failures
+}
+
+func (cb *CircuitBreaker) reset() {
+	for i := range cb.window {
+		cb.window[i] = false
+	}
+	for i := range cb.buckets {
+		cb.buckets[i] = bucket{}
+	}
+	cb.index = 0
+	cb.filled = 0
+	cb.failures = 0
+	cb.successes = 0
+}
+
+// failureRate is the share of failed calls among the calls in the window.
+func (cb *CircuitBreaker) failureRate(now time.Time) float64 {
+	successes, failures := cb.counts(now)
+	if successes+failures == 0 {
+		return 0
+	}
+	return float64(failures) / float64(successes+failures)
+}
+
+func (cb *CircuitBreaker) evaluate(now time.Time) *Event {
+	successes, failures := cb.counts(now)
+	if successes+failures >= cb.policy.MinRequests && cb.failureRate(now) >= cb.policy.FailureThreshold {
+		return cb.setState(Open, now)
+	}
+	return nil
+}
+
+// setState must be called with the lock held. It starts a new generation,
+// so permits issued before the transition no longer count, and clears the
+// window and probe counters. The returned event is passed to emit once the
+// lock is released.
+func (cb *CircuitBreaker) setState(to State, now time.Time) *Event {
+	ev := &Event{
+		Name:        cb.name,
+		From:        cb.state,
+		To:          to,
+		FailureRate: cb.failureRate(now),
+		At:          now,
+	}
+	cb.state = to
+	cb.lastStateChange = now
+	cb.generation++
+	cb.probesAdmitted = 0
+	cb.probeSuccesses = 0
+	if to != Open {
+		cb.reset()
+	}
+	return ev
+}
+
+func (cb *CircuitBreaker) emit(ev *Event) {
+	if ev == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	hooks := append([]func(Event){}, cb.hooks...)
+	cb.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(*ev)
+	}
 }
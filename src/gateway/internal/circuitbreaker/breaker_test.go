@@ -0,0 +1,111 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker_EmptySlotsAreNotOutcomes(t *testing.T) {
+	cb := New(Policy{WindowSize: 10, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+
+	cb.Record(true)
+	cb.Record(false)
+
+	snap := cb.Snapshot()
+	if snap.Successes != 1 || snap.Failures != 1 || len(snap.Window) != 2 {
+		t.Fatalf("unexpected counts: %+v", snap)
+	}
+	if snap.FailureRate != 0.5 {
+		t.Fatalf("failure rate = %v, want 0.5", snap.FailureRate)
+	}
+}
+
+func TestBreaker_MinRequests(t *testing.T) {
+	cb := New(Policy{WindowSize: 10, MinRequests: 3, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+
+	cb.Record(false)
+	cb.Record(false)
+	if !allows(cb) {
+		t.Fatalf("expected breaker to stay closed below the minimum number of calls")
+	}
+
+	cb.Record(false)
+	if allows(cb) {
+		t.Fatalf("expected breaker to open once the minimum is reached")
+	}
+}
+
+func TestBreaker_TimeBucketsForgetOldOutcomes(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cb := New(Policy{WindowSize: 3, BucketWidth: time.Second, MinRequests: 2, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+	cb.now = func() time.Time { return now }
+
+	cb.Record(false)
+	now = now.Add(5 * time.Second)
+	cb.Record(false)
+
+	if !allows(cb) {
+		t.Fatalf("expected a failure outside the window not to count")
+	}
+	if snap := cb.Snapshot(); snap.Failures != 1 || len(snap.Buckets) != 1 {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+
+	now = now.Add(time.Second)
+	cb.Record(true)
+	now = now.Add(time.Second)
+	cb.Record(false)
+
+	if allows(cb) {
+		t.Fatalf("expected breaker to open on 2 failures out of 3 recent calls")
+	}
+}
+
+func TestBreaker_HalfOpenAdmitsLimitedProbes(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cb := New(Policy{WindowSize: 2, FailureThreshold: 0.5, OpenTimeout: time.Second, HalfOpenProbes: 2})
+	cb.now = func() time.Time { return now }
+
+	stale, _ := cb.Acquire()
+	cb.Record(false)
+	now = now.Add(2 * time.Second)
+
+	first, ok1 := cb.Acquire()
+	second, ok2 := cb.Acquire()
+	_, ok3 := cb.Acquire()
+	if !ok1 || !ok2 || ok3 {
+		t.Fatalf("expected exactly 2 probes to be admitted, got %v %v %v", ok1, ok2, ok3)
+	}
+
+	stale.Record(false)
+	first.Record(true)
+	if cb.Snapshot().State != HalfOpen {
+		t.Fatalf("expected a stale outcome and one probe not to decide the state")
+	}
+
+	second.Record(true)
+	if cb.Snapshot().State != Closed {
+		t.Fatalf("expected breaker to close after all probes succeeded")
+	}
+}
+
+func TestBreaker_FailedProbeReopens(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cb := New(Policy{WindowSize: 2, FailureThreshold: 0.5, OpenTimeout: time.Second, HalfOpenProbes: 3})
+	cb.now = func() time.Time { return now }
+
+	cb.Record(false)
+	now = now.Add(2 * time.Second)
+
+	probe, _ := cb.Acquire()
+	probe.Record(false)
+
+	if allows(cb) {
+		t.Fatalf("expected a failed probe to open the breaker again")
+	}
+}
+
+func allows(cb *CircuitBreaker) bool {
+	_, ok := cb.Acquire()
+	return ok
+}
@@ -0,0 +1,165 @@
+package circuitbreaker
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy holds the settings a breaker is created with.
+//
+// With a zero BucketWidth the breaker counts the last WindowSize calls. With
+// a positive BucketWidth it counts the calls of the last WindowSize buckets of
+// that width, so outcomes older than WindowSize*BucketWidth are forgotten.
+// The breaker does not trip before MinRequests calls are in the window.
+// After OpenTimeout it admits HalfOpenProbes trial calls and closes once all
+// of them succeed.
+type Policy struct {
+	WindowSize       int
+	BucketWidth      time.Duration
+	MinRequests      int
+	FailureThreshold float64
+	OpenTimeout      time.Duration
+	HalfOpenProbes   int
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		WindowSize:       10,
+		MinRequests:      5,
+		FailureThreshold: 0.5,
+		OpenTimeout:      5 * time.Second,
+		HalfOpenProbes:   3,
+	}
+}
+
+// Registry keeps one breaker per downstream service and operation, so a
+// failing endpoint does not open the circuit for the rest of the service.
+type Registry struct {
+	mu sync.Mutex
+
+	defaults Policy
+	services map[string]Policy
+	policies map[string]Policy
+	breakers map[string]*CircuitBreaker
+	hooks    []func(Event)
+}
+
+func NewRegistry(defaults Policy) *Registry {
+	return &Registry{
+		defaults: defaults,
+		services: make(map[string]Policy),
+		policies: make(map[string]Policy),
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
+
+func Key(service, operation string) string {
+	return service + "/" + operation
+}
+
+// SetPolicy overrides the default policy for one operation. It only affects
+// breakers created after the call.
+func (r *Registry) SetPolicy(service, operation string, p Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.policies[Key(service, operation)] = p
+}
+
+// SetServicePolicy overrides the default policy for every operation of a
+// service that has no policy of its own. It only affects breakers created
+// after the call.
+func (r *Registry) SetServicePolicy(service string, p Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.services[service] = p
+}
+
+// Get returns the breaker for the operation, creating it on first use.
+func (r *Registry) Get(service, operation string) *CircuitBreaker {
+	key := Key(service, operation)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cb, ok := r.breakers[key]; ok {
+		return cb
+	}
+
+	p, ok := r.policies[key]
+	if !ok {
+		p, ok = r.services[service]
+	}
+	if !ok {
+		p = r.defaults
+	}
+	cb := New(p)
+	cb.name = key
+	cb.hooks = append(cb.hooks, r.hooks...)
+	r.breakers[key] = cb
+	return cb
+}
+
+// OnStateChange registers a hook on every breaker of the registry, including
+// the ones created later.
+func (r *Registry) OnStateChange(fn func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, fn)
+	for _, cb := range r.breakers {
+		cb.OnStateChange(fn)
+	}
+}
+
+// Snapshots reports every breaker created so far, sorted by name.
+func (r *Registry) Snapshots() []Snapshot {
+	keys := r.Keys()
+
+	r.mu.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(keys))
+	for _, k := range keys {
+		breakers = append(breakers, r.breakers[k])
+	}
+	r.mu.Unlock()
+
+	result := make([]Snapshot, 0, len(breakers))
+	for _, cb := range breakers {
+		result = append(result, cb.Snapshot())
+	}
+	return result
+}
+
+// Service returns the breakers created so far for the operations of a
+// service, sorted by name.
+func (r *Registry) Service(service string) []*CircuitBreaker {
+	prefix := Key(service, "")
+	keys := r.Keys()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var breakers []*CircuitBreaker
+	for _, k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			breakers = append(breakers, r.breakers[k])
+		}
+	}
+	return breakers
+}
+
+// Keys lists the breakers created so far in sorted order.
+func (r *Registry) Keys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.breakers))
+	for k := range r.breakers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistry_IsolatesOperations(t *testing.T) {
+	r := NewRegistry(Policy{WindowSize: 4, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+
+	getHotel := r.Get("reservation-service", "getHotel")
+	for i := 0; i < 4; i++ {
+		getHotel.Record(false)
+	}
+
+	if allows(getHotel) {
+		t.Fatalf("expected getHotel breaker to be open")
+	}
+	if !allows(r.Get("reservation-service", "listHotels")) {
+		t.Fatalf("expected listHotels breaker to stay closed")
+	}
+	if r.Get("reservation-service", "getHotel") != getHotel {
+		t.Fatalf("expected the same breaker for the same operation")
+	}
+}
+
+func TestRegistry_PolicyOverride(t *testing.T) {
+	r := NewRegistry(Policy{WindowSize: 10, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+	r.SetPolicy("payment-service", "getPayment", Policy{WindowSize: 2, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+
+	cb := r.Get("payment-service", "getPayment")
+	cb.Record(false)
+
+	if allows(cb) {
+		t.Fatalf("expected overridden policy to trip after one failure in a window of 2")
+	}
+}
+
+func TestRegistry_StateChangeHook(t *testing.T) {
+	r := NewRegistry(Policy{WindowSize: 2, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+
+	var events []Event
+	r.OnStateChange(func(e Event) {
+		events = append(events, e)
+	})
+
+	r.Get("loyalty-service", "getLoyalty").Record(false)
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if e := events[0]; e.Name != "loyalty-service/getLoyalty" || e.From != Closed || e.To != Open {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+
+	snaps := r.Snapshots()
+	if len(snaps) != 1 || snaps[0].State != Open {
+		t.Fatalf("unexpected snapshots: %+v", snaps)
+	}
+}
@@ -1,5 +1,74 @@
 package clients
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+)
 
 var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Downstream service names used as breaker registry keys.
+const (
+	ReservationService = "reservation-service"
+	PaymentService     = "payment-service"
+	LoyaltyService     = "loyalty-service"
+)
+
+// MaxBatchSize is the most UIDs the batch lookups of the downstreams accept
+// in one call.
+const MaxBatchSize = 100
+
+// TotalCountHeader carries the number of items matching a paged list query.
+const TotalCountHeader = "X-Total-Count"
+
+// ReadOperations lists, per service, the breaker operations that only read
+// downstream state.
+var ReadOperations = map[string][]string{
+	ReservationService: {"listHotels", "getHotel", "getHotels", "getReservation", "getReservationsByUser"},
+	PaymentService:     {"getPayment", "getPayments"},
+	LoyaltyService:     {"getLoyalty"},
+}
+
+// WriteOperations lists, per service, the breaker operations that change
+// downstream state, so they can be given a policy of their own.
+var WriteOperations = map[string][]string{
+	ReservationService: {"createReservation", "cancelReservation"},
+	PaymentService:     {"createPayment", "cancelPayment"},
+	LoyaltyService:     {"incrementReservation", "decrementReservation"},
+}
+
+// StatusError is returned when a downstream service answers with an
+// unexpected HTTP status.
+type StatusError struct {
+	Op   string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s status %d", e.Op, e.Code)
+}
+
+// IsRetryable reports whether a client error is transient, so repeating the
+// same call later may succeed: an open breaker, a full bulkhead, a network
+// failure, a timeout, or a 5xx/429 answer. Other 4xx answers and decode errors are final.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, bulkhead.ErrFull) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code >= http.StatusInternalServerError || se.Code == http.StatusTooManyRequests
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne)
+}
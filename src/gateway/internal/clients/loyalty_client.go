@@ -1,47 +1,36 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
 )
 
 type LoyaltyClient struct {
-	baseURL string
-	client  *http.Client
-	breaker *circuitbreaker.CircuitBreaker
+	transport
 }
 
-func NewLoyaltyClient(baseURL string, breaker *circuitbreaker.CircuitBreaker) *LoyaltyClient {
+func NewLoyaltyClient(baseURL string, timeouts Timeouts, retries Retries, breakers *circuitbreaker.Registry, bh *bulkhead.Bulkhead) *LoyaltyClient {
 	return &LoyaltyClient{
-		baseURL: baseURL,
-		client:  &http.Client{},
-		breaker: breaker,
+		transport: newTransport(LoyaltyService, baseURL, timeouts, retries, breakers, bh),
 	}
 }
 
-func (c *LoyaltyClient) GetLoyalty(username string) (model.Loyalty, error) {
-	url := fmt.Sprintf("%s/internal/loyalty/%s", c.baseURL, username)
-
-	if !c.breaker.Allow() {
-		return model.Loyalty{}, ErrCircuitOpen
-	}
-
-	resp, err := c.client.Get(url)
+func (c *LoyaltyClient) GetLoyalty(ctx context.Context, username string) (model.Loyalty, error) {
+	resp, err := c.do(ctx, "getLoyalty", http.MethodGet, "/internal/loyalty/"+url.PathEscape(username), nil)
 	if err != nil {
-		c.breaker.Record(false)
 		return model.Loyalty{}, fmt.Errorf("request loyalty: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 500 {
-		c.breaker.Record(false)
-	} else {
-		c.breaker.Record(true)
+	if resp.StatusCode != http.StatusOK {
+		return model.Loyalty{}, &StatusError{Op: "loyalty", Code: resp.StatusCode}
 	}
 
 	var lo model.Loyalty
@@ -52,45 +41,42 @@ func (c *LoyaltyClient) GetLoyalty(username string) (model.Loyalty, error) {
 	return lo, nil
 }
 
-func (c *LoyaltyClient) IncrementReservation(username string) error {
-	endpoint := fmt.Sprintf("%s/internal/loyalty/%s", c.baseURL, url.PathEscape(username))
-
-	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
-	if err != nil {
-		return fmt.Errorf("build loyalty increment request: %w", err)
-	}
+// IncrementReservation counts the reservation for the user. The call is
+// idempotent per reservation uid.
+func (c *LoyaltyClient) IncrementReservation(ctx context.Context, username, reservationUID string) error {
+	path := "/internal/loyalty/" + url.PathEscape(username)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(ctx, "incrementReservation", http.MethodPost, path, reservationBody(reservationUID))
 	if err != nil {
 		return fmt.Errorf("request loyalty increment: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("loyalty increment status %d", resp.StatusCode)
+		return &StatusError{Op: "loyalty increment", Code: resp.StatusCode}
 	}
 
 	return nil
 }
 
-func (c *LoyaltyClient) DecrementReservation(username string) error {
-	endpoint := fmt.Sprintf("%s/internal/loyalty/%s/decrement", c.baseURL, url.PathEscape(username))
-	fmt.Println("CALL DEC LOYALTY:", endpoint)
+// DecrementReservation removes a counted reservation. The call is idempotent
+// per reservation uid.
+func (c *LoyaltyClient) DecrementReservation(ctx context.Context, username, reservationUID string) error {
+	path := "/internal/loyalty/" + url.PathEscape(username) + "/decrement"
 
-	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
-	if err != nil {
-		return fmt.Errorf("build loyalty decrement request: %w", err)
-	}
-
-	resp, err := c.client.Do(req)
+	resp, err := c.do(ctx, "decrementReservation", http.MethodPost, path, reservationBody(reservationUID))
 	if err != nil {
 		return fmt.Errorf("request loyalty decrement: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("loyalty decrement status %d", resp.StatusCode)
+		return &StatusError{Op: "loyalty decrement", Code: resp.StatusCode}
 	}
 
 	return nil
 }
+
+func reservationBody(reservationUID string) map[string]string {
+	return map[string]string{"reservationUid": reservationUID}
+}
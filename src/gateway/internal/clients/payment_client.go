@@ -1,46 +1,44 @@
 package clients
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
 )
 
 type PaymentClient struct {
-	baseURL string
-	client  *http.Client
-	breaker *circuitbreaker.CircuitBreaker
+	transport
 }
 
-func NewPaymentClient(baseURL string, breaker *circuitbreaker.CircuitBreaker) *PaymentClient {
+func NewPaymentClient(baseURL string, timeouts Timeouts, retries Retries, breakers *circuitbreaker.Registry, bh *bulkhead.Bulkhead) *PaymentClient {
 	return &PaymentClient{
-		baseURL: baseURL,
-		client:  &http.Client{},
-		breaker: breaker,
+		transport: newTransport(PaymentService, baseURL, timeouts, retries, breakers, bh),
 	}
 }
 
-func (c *PaymentClient) CreatePayment(username string, price int) (model.Payment, error) {
+// CreatePayment charges the user. Repeating the call with the same
+// operationKey returns the payment created by the first call.
+func (c *PaymentClient) CreatePayment(ctx context.Context, username string, price int, operationKey string) (model.Payment, error) {
 	body := map[string]interface{}{
-		"username": username,
-		"price":    price,
+		"username":     username,
+		"price":        price,
+		"operationKey": operationKey,
 	}
-	data, _ := json.Marshal(body)
 
-	url := fmt.Sprintf("%s/internal/payments", c.baseURL)
-
-	resp, err := c.client.Post(url, "application/json", bytes.NewReader(data))
+	resp, err := c.do(ctx, "createPayment", http.MethodPost, "/internal/payments", body)
 	if err != nil {
 		return model.Payment{}, fmt.Errorf("create payment: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return model.Payment{}, fmt.Errorf("payment status %d", resp.StatusCode)
+		return model.Payment{}, &StatusError{Op: "payment", Code: resp.StatusCode}
 	}
 
 	var p model.Payment
@@ -51,28 +49,15 @@ func (c *PaymentClient) CreatePayment(username string, price int) (model.Payment
 	return p, nil
 }
 
-func (c *PaymentClient) GetPayment(uid string) (model.Payment, error) {
-	url := fmt.Sprintf("%s/internal/payments/%s", c.baseURL, uid)
-
-	if !c.breaker.Allow() {
-		return model.Payment{}, ErrCircuitOpen
-	}
-
-	resp, err := c.client.Get(url)
+func (c *PaymentClient) GetPayment(ctx context.Context, uid string) (model.Payment, error) {
+	resp, err := c.do(ctx, "getPayment", http.MethodGet, "/internal/payments/"+url.PathEscape(uid), nil)
 	if err != nil {
-		c.breaker.Record(false)
 		return model.Payment{}, fmt.Errorf("get payment: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 500 {
-		c.breaker.Record(false)
-	} else {
-		c.breaker.Record(true)
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return model.Payment{}, fmt.Errorf("payment status %d", resp.StatusCode)
+		return model.Payment{}, &StatusError{Op: "payment", Code: resp.StatusCode}
 	}
 
 	var p model.Payment
@@ -83,23 +68,40 @@ func (c *PaymentClient) GetPayment(uid string) (model.Payment, error) {
 	return p, nil
 }
 
-func (c *PaymentClient) CancelPayment(uid string) error {
-	req, err := http.NewRequest(http.MethodDelete,
-		fmt.Sprintf("%s/internal/payments/%s", c.baseURL, uid),
-		nil,
-	)
+// GetPayments looks up to MaxBatchSize payments in one call. Payments that
+// do not exist are missing from the result.
+func (c *PaymentClient) GetPayments(ctx context.Context, uids []string) (map[string]model.Payment, error) {
+	body := map[string]interface{}{
+		"uids": uids,
+	}
+
+	resp, err := c.do(ctx, "getPayments", http.MethodPost, "/internal/payments/batch", body)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("get payments: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Op: "get payments", Code: resp.StatusCode}
+	}
+
+	var out map[string]model.Payment
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode payments: %w", err)
+	}
+
+	return out, nil
+}
 
-	resp, err := c.client.Do(req)
+func (c *PaymentClient) CancelPayment(ctx context.Context, uid string) error {
+	resp, err := c.do(ctx, "cancelPayment", http.MethodDelete, "/internal/payments/"+url.PathEscape(uid), nil)
 	if err != nil {
 		return fmt.Errorf("cancel payment: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("cancel payment status %d", resp.StatusCode)
+		return &StatusError{Op: "cancel payment", Code: resp.StatusCode}
 	}
 
 	return nil
@@ -1,65 +1,55 @@
 package clients
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
 )
 
 type ReservationClient struct {
-	baseURL string
-	client  *http.Client
-	breaker *circuitbreaker.CircuitBreaker
+	transport
 }
 
-func NewReservationClient(baseURL string, breaker *circuitbreaker.CircuitBreaker) *ReservationClient {
+func NewReservationClient(baseURL string, timeouts Timeouts, retries Retries, breakers *circuitbreaker.Registry, bh *bulkhead.Bulkhead) *ReservationClient {
 	return &ReservationClient{
-		baseURL: baseURL,
-		client:  &http.Client{},
-		breaker: breaker,
+		transport: newTransport(ReservationService, baseURL, timeouts, retries, breakers, bh),
 	}
 }
 
-func (c *ReservationClient) ListHotels(page, size int) (model.HotelsPage, error) {
-	u, err := url.Parse(c.baseURL + "/internal/hotels")
-	if err != nil {
-		return model.HotelsPage{}, err
-	}
-
-	q := u.Query()
-	if page > 0 {
-		q.Set("page", strconv.Itoa(page))
+func (c *ReservationClient) ListHotels(ctx context.Context, page, size int, f model.HotelFilter) (model.HotelsPage, error) {
+	q := url.Values{}
+	for key, value := range map[string]string{"country": f.Country, "city": f.City, "query": f.Query, "sort": f.Sort} {
+		if value != "" {
+			q.Set(key, value)
+		}
 	}
-	if size > 0 {
-		q.Set("size", strconv.Itoa(size))
+	for key, value := range map[string]int{"page": page, "size": size, "minStars": f.MinStars, "minPrice": f.MinPrice, "maxPrice": f.MaxPrice} {
+		if value > 0 {
+			q.Set(key, strconv.Itoa(value))
+		}
 	}
-	u.RawQuery = q.Encode()
 
-	if !c.breaker.Allow() {
-		return model.HotelsPage{}, ErrCircuitOpen
+	path := "/internal/hotels"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
 	}
 
-	resp, err := c.client.Get(u.String())
+	resp, err := c.do(ctx, "listHotels", http.MethodGet, path, nil)
 	if err != nil {
-		c.breaker.Record(false)
 		return model.HotelsPage{}, fmt.Errorf("list hotels: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 500 {
-		c.breaker.Record(false)
-	} else {
-		c.breaker.Record(true)
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return model.HotelsPage{}, fmt.Errorf("list hotels status %d", resp.StatusCode)
+		return model.HotelsPage{}, &StatusError{Op: "list hotels", Code: resp.StatusCode}
 	}
 
 	var out model.HotelsPage
@@ -70,34 +60,20 @@ func (c *ReservationClient) ListHotels(page, size int) (model.HotelsPage, error)
 	return out, nil
 }
 
-func (c *ReservationClient) GetHotel(hotelUID string) (model.Hotel, error) {
-	url := fmt.Sprintf("%s/internal/hotels/%s", c.baseURL, hotelUID)
-
-	if !c.breaker.Allow() {
-		return model.Hotel{}, ErrCircuitOpen
-	}
-
-	resp, err := c.client.Get(url)
+func (c *ReservationClient) GetHotel(ctx context.Context, hotelUID string) (model.Hotel, error) {
+	resp, err := c.do(ctx, "getHotel", http.MethodGet, "/internal/hotels/"+url.PathEscape(hotelUID), nil)
 	if err != nil {
-		c.breaker.Record(false)
 		return model.Hotel{}, fmt.Errorf("get hotel: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		c.breaker.Record(true)
 		return model.Hotel{}, nil
 	}
-	if resp.StatusCode >= 500 {
-		c.breaker.Record(false)
-		return model.Hotel{}, fmt.Errorf("get hotel status %d", resp.StatusCode)
-	}
 	if resp.StatusCode != http.StatusOK {
-		return model.Hotel{}, fmt.Errorf("get hotel status %d", resp.StatusCode)
+		return model.Hotel{}, &StatusError{Op: "get hotel", Code: resp.StatusCode}
 	}
 
-	c.breaker.Record(true)
-
 	var h model.Hotel
 	if err := json.NewDecoder(resp.Body).Decode(&h); err != nil {
 		return model.Hotel{}, fmt.Errorf("decode hotel: %w", err)
@@ -106,7 +82,31 @@ func (c *ReservationClient) GetHotel(hotelUID string) (model.Hotel, error) {
 	return h, nil
 }
 
-func (c *ReservationClient) CreateReservation(req model.ReservationInternal) (model.ReservationFull, error) {
+// GetHotels looks up to MaxBatchSize hotels in one call. Hotels that do not
+// exist are missing from the result.
+func (c *ReservationClient) GetHotels(ctx context.Context, uids []string) (map[string]model.Hotel, error) {
+	q := url.Values{}
+	q.Set("uids", strings.Join(uids, ","))
+
+	resp, err := c.do(ctx, "getHotels", http.MethodGet, "/internal/hotels?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get hotels: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Op: "get hotels", Code: resp.StatusCode}
+	}
+
+	var out map[string]model.Hotel
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode hotels: %w", err)
+	}
+
+	return out, nil
+}
+
+func (c *ReservationClient) CreateReservation(ctx context.Context, req model.ReservationInternal) (model.ReservationFull, error) {
 	var body = struct {
 		Username   string `json:"username"`
 		HotelUID   string `json:"hotelUid"`
@@ -121,18 +121,14 @@ func (c *ReservationClient) CreateReservation(req model.ReservationInternal) (mo
 		PaymentUID: req.PaymentUID,
 	}
 
-	data, _ := json.Marshal(body)
-
-	url := fmt.Sprintf("%s/internal/reservations", c.baseURL)
-
-	resp, err := c.client.Post(url, "application/json", bytes.NewReader(data))
+	resp, err := c.do(ctx, "createReservation", http.MethodPost, "/internal/reservations", body)
 	if err != nil {
 		return model.ReservationFull{}, fmt.Errorf("create reservation: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return model.ReservationFull{}, fmt.Errorf("reservation status %d", resp.StatusCode)
+		return model.ReservationFull{}, &StatusError{Op: "reservation", Code: resp.StatusCode}
 	}
 
 	var out model.ReservationFull
@@ -144,34 +140,20 @@ func (c *ReservationClient) CreateReservation(req model.ReservationInternal) (mo
 	return out, nil
 }
 
-func (c *ReservationClient) GetReservation(uid string) (model.ReservationFull, error) {
-	url := fmt.Sprintf("%s/internal/reservations/%s", c.baseURL, uid)
-
-	if !c.breaker.Allow() {
-		return model.ReservationFull{}, ErrCircuitOpen
-	}
-
-	resp, err := c.client.Get(url)
+func (c *ReservationClient) GetReservation(ctx context.Context, uid string) (model.ReservationFull, error) {
+	resp, err := c.do(ctx, "getReservation", http.MethodGet, "/internal/reservations/"+url.PathEscape(uid), nil)
 	if err != nil {
-		c.breaker.Record(false)
 		return model.ReservationFull{}, fmt.Errorf("get reservation: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		c.breaker.Record(true)
 		return model.ReservationFull{}, nil
 	}
-	if resp.StatusCode >= http.StatusInternalServerError {
-		c.breaker.Record(false)
-		return model.ReservationFull{}, fmt.Errorf("reservation status %d", resp.StatusCode)
-	}
 	if resp.StatusCode != http.StatusOK {
-		return model.ReservationFull{}, fmt.Errorf("reservation status %d", resp.StatusCode)
+		return model.ReservationFull{}, &StatusError{Op: "reservation", Code: resp.StatusCode}
 	}
 
-	c.breaker.Record(true)
-
 	var out model.ReservationFull
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
 		return model.ReservationFull{}, fmt.Errorf("decode reservation: %w", err)
@@ -180,56 +162,58 @@ func (c *ReservationClient) GetReservation(uid string) (model.ReservationFull, e
 	return out, nil
 }
 
-func (c *ReservationClient) GetReservationsByUser(username string) ([]model.ReservationFull, error) {
-	url := fmt.Sprintf("%s/internal/reservations/byUser/%s", c.baseURL, username)
+// GetReservationsByUser returns the page of a user's reservations selected
+// by f and the number of matches in total.
+func (c *ReservationClient) GetReservationsByUser(ctx context.Context, username string, f model.ReservationFilter) ([]model.ReservationFull, int, error) {
+	q := url.Values{}
+	for key, value := range map[string]string{"status": f.Status, "from": f.From, "to": f.To, "when": f.When, "sort": f.Sort} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	if f.Page > 0 {
+		q.Set("page", strconv.Itoa(f.Page))
+	}
+	if f.Size > 0 {
+		q.Set("size", strconv.Itoa(f.Size))
+	}
 
-	if !c.breaker.Allow() {
-		return nil, ErrCircuitOpen
+	path := "/internal/reservations/byUser/" + url.PathEscape(username)
+	if len(q) > 0 {
+		path += "?" + q.Encode()
 	}
 
-	resp, err := c.client.Get(url)
+	resp, err := c.do(ctx, "getReservationsByUser", http.MethodGet, path, nil)
 	if err != nil {
-		c.breaker.Record(false)
-		return nil, fmt.Errorf("list reservations: %w", err)
+		return nil, 0, fmt.Errorf("list reservations: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= http.StatusInternalServerError {
-		c.breaker.Record(false)
-		return nil, fmt.Errorf("reservation status %d", resp.StatusCode)
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("reservations status %d", resp.StatusCode)
+		return nil, 0, &StatusError{Op: "reservations", Code: resp.StatusCode}
 	}
 
-	c.breaker.Record(true)
-
 	var out []model.ReservationFull
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return nil, fmt.Errorf("decode reservations: %w", err)
+		return nil, 0, fmt.Errorf("decode reservations: %w", err)
 	}
 
-	return out, nil
-}
-
-func (c *ReservationClient) CancelReservation(uid string) error {
-	req, err := http.NewRequest(http.MethodDelete,
-		fmt.Sprintf("%s/internal/reservations/%s", c.baseURL, uid),
-		nil,
-	)
+	total, err := strconv.Atoi(resp.Header.Get(TotalCountHeader))
 	if err != nil {
-		return err
+		total = len(out)
 	}
+	return out, total, nil
+}
 
-	resp, err := c.client.Do(req)
+func (c *ReservationClient) CancelReservation(ctx context.Context, uid string) error {
+	resp, err := c.do(ctx, "cancelReservation", http.MethodDelete, "/internal/reservations/"+url.PathEscape(uid), nil)
 	if err != nil {
 		return fmt.Errorf("cancel reservation: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("cancel reservation status %d", resp.StatusCode)
+		return &StatusError{Op: "cancel reservation", Code: resp.StatusCode}
 	}
 
 	return nil
@@ -0,0 +1,36 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+func TestGetReservationsByUser_PassesFilterAndReadsTotal(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Header().Set(TotalCountHeader, "42")
+		w.Write([]byte(`[{"reservationUid":"r1"}]`))
+	}))
+	defer srv.Close()
+
+	c := NewReservationClient(srv.URL, Timeouts{Default: time.Second}, Retries{}, circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy()), bulkhead.New(ReservationService, 4, 0))
+	out, total, err := c.GetReservationsByUser(context.Background(), "Test Max", model.ReservationFilter{Status: "PAID", Sort: "-startDate", Page: 3, Size: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "page=3&size=1&sort=-startDate&status=PAID"; query != want {
+		t.Fatalf("expected query %q, got %q", want, query)
+	}
+	if len(out) != 1 || total != 42 {
+		t.Fatalf("expected one reservation of 42, got %d of %d", len(out), total)
+	}
+}
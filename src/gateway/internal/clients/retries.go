@@ -0,0 +1,227 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/retry"
+)
+
+const (
+	// retryBudgetBurst is the number of retries a client may make before
+	// the budget has to be earned back by calls.
+	retryBudgetBurst = 10
+
+	// latencySamples is how many recent latencies per operation the hedging
+	// delay is computed from, and hedgeMinSamples how many are needed
+	// before hedging starts.
+	latencySamples  = 128
+	hedgeMinSamples = 20
+)
+
+// Retries controls how the GET calls of one client are repeated. Policy
+// bounds the attempts and the delay between them; Budget is the share of
+// calls that may be retried, so that retries cannot multiply the load on a
+// failing downstream. A positive HedgePercentile sends a second request once
+// an attempt has run longer than that percentile of recent ones.
+type Retries struct {
+	Policy          retry.Policy
+	Budget          float64
+	HedgePercentile float64
+}
+
+// retrying repeats a GET after transport errors, attempt timeouts and 5xx or
+// 429 answers, as long as the policy and the budget allow it.
+func (t *transport) retrying(ctx context.Context, op, path string) (*http.Response, error) {
+	t.budget.Deposit()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.hedged(ctx, op, path)
+		if !worthRetrying(resp, err) || ctx.Err() != nil || t.retries.Policy.Exhausted(attempt) || !t.budget.Withdraw() {
+			return resp, err
+		}
+		discard(resp)
+
+		timer := time.NewTimer(t.retries.Policy.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func worthRetrying(resp *http.Response, err error) bool {
+	if err != nil {
+		var rej *rejected
+		return !errors.As(err, &rej) && !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+type attemptResult struct {
+	index  int
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+// hedged sends one attempt and, if it has not answered within the hedging
+// delay of op and the budget allows it, a second one. The first good answer
+// wins and the other attempt is cancelled.
+func (t *transport) hedged(ctx context.Context, op, path string) (*http.Response, error) {
+	delay, ok := t.hedgeDelay(op)
+	if !ok {
+		return t.timed(ctx, op, path)
+	}
+
+	results := make(chan attemptResult, 2)
+	var cancels []context.CancelFunc
+	send := func() {
+		actx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.timed(actx, op, path)
+			results <- attemptResult{index: index, resp: resp, err: err, cancel: cancel}
+		}()
+	}
+
+	send()
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.winner()
+	case <-timer.C:
+	}
+	if t.budget.Withdraw() {
+		send()
+		pending++
+	}
+
+	for {
+		r := <-results
+		pending--
+		if pending > 0 && !good(r) {
+			r.discard()
+			continue
+		}
+		for i, cancel := range cancels {
+			if i != r.index {
+				cancel()
+			}
+		}
+		for ; pending > 0; pending-- {
+			go (<-results).discard()
+		}
+		return r.winner()
+	}
+}
+
+// timed runs one attempt and records its latency when it was answered.
+func (t *transport) timed(ctx context.Context, op, path string) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.attempt(ctx, op, http.MethodGet, path, nil)
+	if good(attemptResult{resp: resp, err: err}) {
+		t.latencies.observe(op, time.Since(start))
+	}
+	return resp, err
+}
+
+func (t *transport) hedgeDelay(op string) (time.Duration, bool) {
+	if t.retries.HedgePercentile <= 0 {
+		return 0, false
+	}
+	return t.latencies.percentile(op, t.retries.HedgePercentile)
+}
+
+func good(r attemptResult) bool {
+	return r.err == nil && r.resp.StatusCode < http.StatusInternalServerError
+}
+
+// winner hands the response over to the caller, whose closing of the body
+// also releases the hedging context.
+func (r attemptResult) winner() (*http.Response, error) {
+	if r.cancel == nil {
+		return r.resp, r.err
+	}
+	if r.err != nil {
+		r.cancel()
+		return nil, r.err
+	}
+	r.resp.Body = &closeNotifier{ReadCloser: r.resp.Body, done: r.cancel}
+	return r.resp, nil
+}
+
+func (r attemptResult) discard() {
+	discard(r.resp)
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+func discard(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// latencies keeps the durations of the last answered attempts of every
+// operation.
+type latencies struct {
+	mu  sync.Mutex
+	ops map[string]*latencyRing
+}
+
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+}
+
+func newLatencies() *latencies {
+	return &latencies{ops: make(map[string]*latencyRing)}
+}
+
+func (l *latencies) observe(op string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r, ok := l.ops[op]
+	if !ok {
+		r = &latencyRing{}
+		l.ops[op] = r
+	}
+	if len(r.samples) < latencySamples {
+		r.samples = append(r.samples, d)
+		return
+	}
+	r.samples[r.next] = d
+	r.next = (r.next + 1) % latencySamples
+}
+
+// percentile returns the p-th latency of op, or false while too few
+// attempts have been seen to tell.
+func (l *latencies) percentile(op string, p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	r, ok := l.ops[op]
+	if !ok || len(r.samples) < hedgeMinSamples {
+		l.mu.Unlock()
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), r.samples...)
+	l.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(p*float64(len(sorted)))], true
+}
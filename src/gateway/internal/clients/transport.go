@@ -0,0 +1,196 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/retry"
+)
+
+// DeadlineHeader carries the time left for a call in milliseconds, so that a
+// downstream can stop working on a request the gateway has given up on.
+const DeadlineHeader = "X-Request-Timeout-Ms"
+
+// Timeouts bounds the calls to one downstream. Operations without an entry
+// in PerOperation use Default.
+type Timeouts struct {
+	Default      time.Duration
+	PerOperation map[string]time.Duration
+}
+
+func (t Timeouts) For(op string) time.Duration {
+	if d, ok := t.PerOperation[op]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// transport sends the requests of one client. Every call runs under the
+// breaker of its operation, each attempt takes a slot of the client's
+// bulkhead and is bounded by the operation timeout. GET calls are retried
+// and hedged as described by Retries.
+type transport struct {
+	service   string
+	baseURL   string
+	client    *http.Client
+	breakers  *circuitbreaker.Registry
+	bulkhead  *bulkhead.Bulkhead
+	timeouts  Timeouts
+	retries   Retries
+	budget    *retry.Budget
+	latencies *latencies
+}
+
+func newTransport(service, baseURL string, timeouts Timeouts, retries Retries, breakers *circuitbreaker.Registry, bh *bulkhead.Bulkhead) transport {
+	return transport{
+		service:   service,
+		baseURL:   baseURL,
+		client:    &http.Client{},
+		breakers:  breakers,
+		bulkhead:  bh,
+		timeouts:  timeouts,
+		retries:   retries,
+		budget:    retry.NewBudget(retries.Budget, retryBudgetBurst),
+		latencies: newLatencies(),
+	}
+}
+
+func (t *transport) Bulkhead() *bulkhead.Bulkhead {
+	return t.bulkhead
+}
+
+// CheckHealth asks the downstream for its health. The check bypasses the
+// breakers and the bulkhead, so it still reaches a service they shut out.
+func (t *transport) CheckHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.baseURL+"/manage/health", nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Op: "health", Code: resp.StatusCode}
+	}
+	return nil
+}
+
+// do sends a request for op with body encoded as JSON when it is not nil.
+// The call is recorded in the breaker once, however many attempts it took:
+// answers of 5xx and transport errors count as failures, while a call the
+// caller cancelled or the bulkhead turned away does not count at all. The
+// caller must close the response body, which also releases the bulkhead slot
+// and the operation timeout.
+func (t *transport) do(ctx context.Context, op, method, path string, body interface{}) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	permit, ok := t.breakers.Get(t.service, op).Acquire()
+	if !ok {
+		return nil, ErrCircuitOpen
+	}
+
+	var resp *http.Response
+	var err error
+	if method == http.MethodGet {
+		resp, err = t.retrying(ctx, op, path)
+	} else {
+		resp, err = t.attempt(ctx, op, method, path, data)
+	}
+
+	var rej *rejected
+	switch {
+	case err == nil:
+		permit.Record(resp.StatusCode < http.StatusInternalServerError)
+	case errors.As(err, &rej) || errors.Is(err, context.Canceled):
+		permit.Release()
+	default:
+		permit.Record(false)
+	}
+	return resp, err
+}
+
+// attempt sends the request once.
+func (t *transport) attempt(ctx context.Context, op, method, path string, data []byte) (*http.Response, error) {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeouts.For(op))
+
+	req, err := http.NewRequestWithContext(ctx, method, t.baseURL+path, reader)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(DeadlineHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+
+	if err := t.bulkhead.Acquire(ctx); err != nil {
+		cancel()
+		return nil, &rejected{err: err}
+	}
+	done := func() {
+		t.bulkhead.Release()
+		cancel()
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	resp.Body = &closeNotifier{ReadCloser: resp.Body, done: done}
+	return resp, nil
+}
+
+// rejected wraps the error of an attempt that never reached the downstream,
+// which says nothing about its health and is not worth repeating.
+type rejected struct {
+	err error
+}
+
+func (r *rejected) Error() string {
+	return r.err.Error()
+}
+
+func (r *rejected) Unwrap() error {
+	return r.err
+}
+
+type closeNotifier struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (b *closeNotifier) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
@@ -0,0 +1,197 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/retry"
+)
+
+func TestTransport_PropagatesDeadline(t *testing.T) {
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(DeadlineHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tr := newTransport(PaymentService, srv.URL, Timeouts{Default: time.Second}, Retries{}, circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy()), bulkhead.New(PaymentService, 4, 0))
+	resp, err := tr.do(context.Background(), "getPayment", http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	ms, err := strconv.Atoi(header)
+	if err != nil || ms <= 0 || ms > 1000 {
+		t.Fatalf("unexpected deadline header %q", header)
+	}
+}
+
+func TestTransport_OperationTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	timeouts := Timeouts{Default: time.Second, PerOperation: map[string]time.Duration{"getHotel": 20 * time.Millisecond}}
+	tr := newTransport(ReservationService, srv.URL, timeouts, Retries{}, circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy()), bulkhead.New(ReservationService, 4, 0))
+
+	start := time.Now()
+	_, err := tr.do(context.Background(), "getHotel", http.MethodGet, "/", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if !IsRetryable(err) {
+		t.Fatalf("expected a timeout to be retryable")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("operation timeout not applied, took %v", elapsed)
+	}
+}
+
+func TestTransport_CallerCancelDoesNotCountAsFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.Policy{WindowSize: 1, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+	tr := newTransport(LoyaltyService, srv.URL, Timeouts{Default: time.Second}, Retries{}, breakers, bulkhead.New(LoyaltyService, 4, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := tr.do(ctx, "getLoyalty", http.MethodGet, "/", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+
+	if snap := breakers.Get(LoyaltyService, "getLoyalty").Snapshot(); snap.Failures != 0 || snap.State != circuitbreaker.Closed {
+		t.Fatalf("expected cancelled call not to be recorded: %+v", snap)
+	}
+}
+
+func TestTransport_FullBulkheadFailsFast(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.Policy{WindowSize: 1, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+	tr := newTransport(PaymentService, srv.URL, Timeouts{Default: time.Second}, Retries{}, breakers, bulkhead.New(PaymentService, 1, 0))
+
+	go func() {
+		if resp, err := tr.do(context.Background(), "getPayment", http.MethodGet, "/", nil); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	for tr.Bulkhead().Snapshot().InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := tr.do(context.Background(), "getPayment", http.MethodGet, "/", nil)
+	if !errors.Is(err, bulkhead.ErrFull) {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+	if snap := breakers.Get(PaymentService, "getPayment").Snapshot(); snap.Failures != 0 {
+		t.Fatalf("expected a rejected call not to count as a breaker failure: %+v", snap)
+	}
+}
+
+func TestTransport_RetriesGetAndRecordsOnce(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy())
+	retries := Retries{Policy: retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, Budget: 0.2}
+	tr := newTransport(PaymentService, srv.URL, Timeouts{Default: time.Second}, retries, breakers, bulkhead.New(PaymentService, 4, 0))
+
+	resp, err := tr.do(context.Background(), "getPayment", http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected a retry to succeed, got status %d after %d calls", resp.StatusCode, calls)
+	}
+	if snap := breakers.Get(PaymentService, "getPayment").Snapshot(); snap.Successes != 1 || snap.Failures != 0 {
+		t.Fatalf("expected the call to be recorded once as a success: %+v", snap)
+	}
+}
+
+func TestTransport_DoesNotRetryWrites(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	retries := Retries{Policy: retry.Policy{MaxAttempts: 3}, Budget: 0.2}
+	tr := newTransport(PaymentService, srv.URL, Timeouts{Default: time.Second}, retries, circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy()), bulkhead.New(PaymentService, 4, 0))
+
+	resp, err := tr.do(context.Background(), "createPayment", http.MethodPost, "/", map[string]string{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected a single POST, got %d", n)
+	}
+}
+
+func TestTransport_HedgesSlowGet(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	retries := Retries{Policy: retry.Policy{MaxAttempts: 1}, Budget: 0.2, HedgePercentile: 0.9}
+	tr := newTransport(LoyaltyService, srv.URL, Timeouts{Default: 2 * time.Second}, retries, circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy()), bulkhead.New(LoyaltyService, 4, 0))
+	for i := 0; i < hedgeMinSamples; i++ {
+		tr.latencies.observe("getLoyalty", 10*time.Millisecond)
+	}
+
+	start := time.Now()
+	resp, err := tr.do(context.Background(), "getLoyalty", http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected the hedged request to answer, got status %d after %d calls", resp.StatusCode, calls)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("hedged request did not win, took %v", elapsed)
+	}
+}
@@ -0,0 +1,120 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats is a point-in-time view of a group for reporting. Coalesced counts
+// the requests that joined a call already in flight instead of making their
+// own.
+type Stats struct {
+	Name      string `json:"name"`
+	Requests  int64  `json:"requests"`
+	Coalesced int64  `json:"coalesced"`
+	InFlight  int    `json:"inFlight"`
+}
+
+// Group merges concurrent calls with the same key into one, whose result is
+// handed to every caller. As a single downstream call is made, it is also
+// recorded in the breaker once, and a breaker that is open turns all the
+// callers away together.
+//
+// The call runs under a context of its own that keeps the values of the
+// first caller. A caller whose context ends stops waiting without affecting
+// the others; the call is cancelled once no caller is left.
+type Group[V any] struct {
+	name string
+
+	mu    sync.Mutex
+	calls map[string]*call[V]
+
+	requests  atomic.Int64
+	coalesced atomic.Int64
+}
+
+type call[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func New[V any](name string) *Group[V] {
+	return &Group[V]{
+		name:  name,
+		calls: make(map[string]*call[V]),
+	}
+}
+
+// Do returns the result of fn for key, joining a call already in flight for
+// the same key if there is one.
+func (g *Group[V]) Do(ctx context.Context, key string, fn func(ctx context.Context) (V, error)) (V, error) {
+	g.requests.Add(1)
+
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if ok {
+		g.coalesced.Add(1)
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call[V]{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+		go g.run(callCtx, key, c, fn)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		g.leave(key, c)
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (g *Group[V]) run(ctx context.Context, key string, c *call[V], fn func(ctx context.Context) (V, error)) {
+	c.val, c.err = fn(ctx)
+	c.cancel()
+
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+
+	close(c.done)
+}
+
+// leave drops a caller that stopped waiting. The last one to leave cancels
+// the call and lets the next caller start a fresh one.
+func (g *Group[V]) leave(key string, c *call[V]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c.waiters--
+	if c.waiters > 0 {
+		return
+	}
+	c.cancel()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
+
+func (g *Group[V]) Stats() Stats {
+	g.mu.Lock()
+	inFlight := len(g.calls)
+	g.mu.Unlock()
+
+	return Stats{
+		Name:      g.name,
+		Requests:  g.requests.Load(),
+		Coalesced: g.coalesced.Load(),
+		InFlight:  inFlight,
+	}
+}
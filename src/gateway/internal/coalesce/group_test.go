@@ -0,0 +1,113 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_SharesInFlightCall(t *testing.T) {
+	g := New[int]("getHotel")
+
+	var calls int32
+	release := make(chan struct{})
+	fn := func(context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = g.Do(context.Background(), "h1", fn)
+		}(i)
+	}
+	for g.Stats().Requests < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected one call, got %d", n)
+	}
+	for _, r := range results {
+		if r != 42 {
+			t.Fatalf("expected every caller to get the shared result, got %v", results)
+		}
+	}
+	if s := g.Stats(); s.Requests != 5 || s.Coalesced != 4 || s.InFlight != 0 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestGroup_CallerCancelLeavesOthersWaiting(t *testing.T) {
+	g := New[int]("getLoyalty")
+
+	release := make(chan struct{})
+	var callCtx context.Context
+	fn := func(ctx context.Context) (int, error) {
+		callCtx = ctx
+		<-release
+		return 1, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.Do(ctx, "alice", fn)
+		first <- err
+	}()
+	second := make(chan error, 1)
+	for g.Stats().InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		_, err := g.Do(context.Background(), "alice", fn)
+		second <- err
+	}()
+	for g.Stats().Coalesced == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to stop waiting, got %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("expected the call to go on for the remaining caller, got %v", err)
+	}
+	if callCtx.Err() == nil {
+		t.Fatalf("expected the call context to be released after the call")
+	}
+}
+
+func TestGroup_LastCallerCancelsCall(t *testing.T) {
+	g := New[int]("listHotels")
+
+	cancelled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go g.Do(ctx, "1:10", func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return 0, ctx.Err()
+	})
+	for g.Stats().InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the call to be cancelled once no caller waits")
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Payment.Breaker.Window != 10 || cfg.Payment.Breaker.Threshold != 0.5 || cfg.Payment.HTTPTimeout != 5*time.Second {
+		t.Fatalf("unexpected defaults: %+v", cfg.Payment)
+	}
+}
+
+func TestLoad_PerDownstreamOverridesShared(t *testing.T) {
+	t.Setenv("CB_WINDOW", "20")
+	t.Setenv("LOYALTY_CB_WINDOW", "6")
+	t.Setenv("LOYALTY_HTTP_TIMEOUT", "750ms")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Reservation.Breaker.Window != 20 {
+		t.Fatalf("reservation window = %d, want 20", cfg.Reservation.Breaker.Window)
+	}
+	if cfg.Loyalty.Breaker.Window != 6 || cfg.Loyalty.HTTPTimeout != 750*time.Millisecond {
+		t.Fatalf("unexpected loyalty settings: %+v", cfg.Loyalty)
+	}
+}
+
+func TestLoad_WriteBreakerDefaultsToRead(t *testing.T) {
+	t.Setenv("PAYMENT_CB_OPEN_TIMEOUT", "10s")
+	t.Setenv("PAYMENT_WRITE_CB_WINDOW", "20")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w := cfg.Payment.WriteBreaker; w.Window != 20 || w.OpenTimeout != 10*time.Second {
+		t.Fatalf("unexpected write breaker: %+v", w)
+	}
+	if cfg.Payment.Breaker.Window != 10 {
+		t.Fatalf("read window = %d, want 10", cfg.Payment.Breaker.Window)
+	}
+}
+
+func TestLoad_OperationTimeouts(t *testing.T) {
+	t.Setenv("RESERVATION_OPERATION_TIMEOUTS", "getHotel=2s, listHotels=500ms")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Reservation.OperationTimeouts; got["getHotel"] != 2*time.Second || got["listHotels"] != 500*time.Millisecond {
+		t.Fatalf("unexpected operation timeouts: %v", got)
+	}
+
+	t.Setenv("RESERVATION_OPERATION_TIMEOUTS", "getHotel")
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected an entry without a duration to be rejected")
+	}
+}
+
+func TestLoad_FileBelowEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	data := `{"PAYMENT_CB_FAILURE_THRESHOLD": 0.8, "PAYMENT_CB_OPEN_TIMEOUT": "30s", "PAYMENT_URL": "http://file"}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("PAYMENT_URL", "http://env")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Payment.Breaker.Threshold != 0.8 || cfg.Payment.Breaker.OpenTimeout != 30*time.Second {
+		t.Fatalf("file values not applied: %+v", cfg.Payment)
+	}
+	if cfg.Payment.URL != "http://env" {
+		t.Fatalf("url = %q, want environment value", cfg.Payment.URL)
+	}
+}
+
+func TestLoad_RejectsInvalid(t *testing.T) {
+	for key, value := range map[string]string{
+		"RESERVATION_CB_WINDOW":       "0",
+		"CB_FAILURE_THRESHOLD":        "1.5",
+		"PAYMENT_HTTP_TIMEOUT":        "soon",
+		"LOYALTY_RETRY_ATTEMPTS":      "-1",
+		"SAGA_BACKOFF_BASE":           "10m",
+		"RESERVATION_CB_OPEN_TIMEOUT": "0s",
+		"CB_MIN_REQUESTS":             "11",
+		"PAYMENT_RETRY_BUDGET":        "2",
+		"HEDGE_PERCENTILE":            "1",
+		"HEALTH_CHECK_TIMEOUT":        "1m",
+		"ENRICH_CONCURRENCY":          "0",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := Load(); err == nil {
+				t.Fatalf("%s=%s: expected error", key, value)
+			}
+		})
+	}
+}
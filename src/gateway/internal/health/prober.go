@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Statuses a dependency, or the gateway as a whole, is reported with. A
+// dependency is Unknown until its first probe has finished.
+const (
+	Up       = "up"
+	Degraded = "degraded"
+	Down     = "down"
+	Unknown  = "unknown"
+)
+
+// Check probes one dependency and fails when it is not healthy.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of the last probe of one dependency.
+type Result struct {
+	Name      string
+	Status    string
+	Latency   time.Duration
+	Err       error
+	CheckedAt time.Time
+}
+
+// Prober checks its dependencies every interval, each probe bounded by
+// timeout, and keeps the last result of every one.
+type Prober struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	names  []string
+	checks map[string]Check
+
+	mu      sync.Mutex
+	results map[string]Result
+	hooks   []func(Result)
+}
+
+func NewProber(interval, timeout time.Duration) *Prober {
+	return &Prober{
+		interval: interval,
+		timeout:  timeout,
+		checks:   make(map[string]Check),
+		results:  make(map[string]Result),
+	}
+}
+
+// Add registers a dependency. It must be called before Run.
+func (p *Prober) Add(name string, check Check) {
+	p.names = append(p.names, name)
+	p.checks[name] = check
+}
+
+// OnResult registers a hook called after every probe.
+func (p *Prober) OnResult(fn func(Result)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.hooks = append(p.hooks, fn)
+}
+
+// Run probes all dependencies right away and then every interval until ctx
+// ends.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.ProbeAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll checks every dependency concurrently and waits for the results.
+func (p *Prober) ProbeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, name := range p.names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			p.probe(ctx, name)
+		}(name)
+	}
+	wg.Wait()
+}
+
+func (p *Prober) probe(ctx context.Context, name string) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.checks[name](ctx)
+	r := Result{
+		Name:      name,
+		Status:    Up,
+		Latency:   time.Since(start),
+		Err:       err,
+		CheckedAt: start,
+	}
+	if err != nil {
+		r.Status = Down
+	}
+
+	p.mu.Lock()
+	p.results[name] = r
+	hooks := append([]func(Result){}, p.hooks...)
+	p.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(r)
+	}
+}
+
+// Results reports the last result of every dependency in the order they
+// were added.
+func (p *Prober) Results() []Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	results := make([]Result, 0, len(p.names))
+	for _, name := range p.names {
+		r, ok := p.results[name]
+		if !ok {
+			r = Result{Name: name, Status: Unknown}
+		}
+		results = append(results, r)
+	}
+	return results
+}
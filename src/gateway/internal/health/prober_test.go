@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProber_RecordsResults(t *testing.T) {
+	p := NewProber(time.Minute, time.Second)
+	p.Add("up", func(context.Context) error { return nil })
+	p.Add("down", func(context.Context) error { return errors.New("connection refused") })
+
+	if r := p.Results(); r[0].Status != Unknown || r[1].Status != Unknown {
+		t.Fatalf("expected unprobed dependencies to be unknown: %+v", r)
+	}
+
+	var seen []string
+	p.OnResult(func(r Result) {
+		if r.Status == Down {
+			seen = append(seen, r.Name)
+		}
+	})
+	p.ProbeAll(context.Background())
+
+	r := p.Results()
+	if r[0].Name != "up" || r[0].Status != Up || r[0].CheckedAt.IsZero() {
+		t.Fatalf("unexpected result: %+v", r[0])
+	}
+	if r[1].Status != Down || r[1].Err == nil {
+		t.Fatalf("unexpected result: %+v", r[1])
+	}
+	if len(seen) != 1 || seen[0] != "down" {
+		t.Fatalf("expected the hook to see the failed probe, got %v", seen)
+	}
+}
+
+func TestProber_BoundsProbesByTimeout(t *testing.T) {
+	p := NewProber(time.Minute, 20*time.Millisecond)
+	p.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	p.ProbeAll(context.Background())
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("probe timeout not applied, took %v", elapsed)
+	}
+	if r := p.Results()[0]; r.Status != Down {
+		t.Fatalf("expected a timed out probe to be down: %+v", r)
+	}
+}
@@ -3,9 +3,14 @@ package httpserver
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/health"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/service"
 )
 
@@ -22,11 +27,44 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	if r.URL.Query().Get("deep") == "true" {
+		h.Ready(w, r)
+		return
+	}
 	if err := h.svc.Health(r.Context()); err != nil {
 		WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unhealthy"})
 		return
 	}
-	WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "ok",
+		"bulkheads":  h.svc.Bulkheads(r.Context()),
+		"coalescing": h.svc.Coalescing(r.Context()),
+	})
+}
+
+// Ready reports the health of every downstream. It answers 503 only when
+// the gateway is down, since a degraded gateway still serves requests.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	report := h.svc.Readiness(r.Context())
+	status := http.StatusOK
+	if report.Status == health.Down {
+		status = http.StatusServiceUnavailable
+	}
+	WriteJSON(w, status, report)
+}
+
+// writeServiceError answers 503 when a downstream had no capacity left for
+// the call and 500 for any other failure.
+func writeServiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrOverloaded) {
+		WriteError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	WriteError(w, http.StatusInternalServerError, err.Error())
 }
 
 func getUsername(r *http.Request) string {
@@ -39,14 +77,33 @@ func (h *Handler) Hotels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	q := r.URL.Query()
-	page := parseIntOrDefault(q.Get("page"), 1)
-	size := parseIntOrDefault(q.Get("size"), 10)
+	page, err := parseFilterInt(q, "page", 1, 1)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	size, err := parseFilterInt(q, "size", 10, 1)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter, err := parseHotelFilter(q)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	resp, err := h.svc.ListHotels(r.Context(), page, size)
+	ctx, stale := service.WithStaleness(r.Context())
+	resp, err := h.svc.ListHotels(ctx, page, size, filter)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, service.ErrInvalidFilter) {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeServiceError(w, err)
 		return
 	}
+	WriteStaleHeaders(w, stale)
 	WriteJSON(w, http.StatusOK, resp)
 }
 
@@ -61,11 +118,13 @@ func (h *Handler) Loyalty(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.svc.GetLoyalty(username)
+	ctx, stale := service.WithStaleness(r.Context())
+	resp, err := h.svc.GetLoyalty(ctx, username)
 	if err != nil {
 		WriteError(w, http.StatusServiceUnavailable, "Loyalty Service unavailable")
 		return
 	}
+	WriteStaleHeaders(w, stale)
 	WriteJSON(w, http.StatusOK, resp)
 }
 
@@ -79,11 +138,36 @@ func (h *Handler) ListReservations(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusUnauthorized, "missing X-User-Name header")
 		return
 	}
-	resp, err := h.svc.ListUserReservations(r.Context(), username)
+	q := r.URL.Query()
+	filter := model.ReservationFilter{
+		Status: q.Get("status"),
+		From:   q.Get("from"),
+		To:     q.Get("to"),
+		When:   q.Get("when"),
+		Sort:   q.Get("sort"),
+	}
+	var err error
+	if filter.Page, err = parseFilterInt(q, "page", 1, 1); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.Size, err = parseFilterInt(q, "size", 0, 1); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, stale := service.WithStaleness(r.Context())
+	resp, total, err := h.svc.ListUserReservations(ctx, username, filter)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, service.ErrInvalidFilter) {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeServiceError(w, err)
 		return
 	}
+	WriteStaleHeaders(w, stale)
+	w.Header().Set(TotalCountHeader, strconv.Itoa(total))
 	WriteJSON(w, http.StatusOK, resp)
 }
 
@@ -109,13 +193,27 @@ func (h *Handler) CreateReservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.svc.CreateReservation(r.Context(), username, body.HotelUID, body.StartDate, body.EndDate)
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+
+	resp, err := h.svc.CreateReservation(r.Context(), username, body.HotelUID, body.StartDate, body.EndDate, idempotencyKey)
 	if err != nil {
 		if errors.Is(err, service.ErrServiceUnavailable) {
 			WriteError(w, http.StatusServiceUnavailable, "Loyalty Service unavailable")
 			return
 		}
-		WriteError(w, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, service.ErrInvalidReservation) {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrIdempotencyKeyReused) {
+			WriteError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrIdempotencyKeyInProgress) {
+			WriteError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeServiceError(w, err)
 		return
 	}
 
@@ -139,20 +237,55 @@ func (h *Handler) GetReservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.svc.GetReservation(r.Context(), username, reservationUID)
+	ctx, stale := service.WithStaleness(r.Context())
+	resp, err := h.svc.GetReservation(ctx, username, reservationUID)
 
 	if err != nil {
 		if err.Error() == "forbidden" {
 			WriteError(w, http.StatusForbidden, "forbidden")
 			return
 		}
-		WriteError(w, http.StatusInternalServerError, err.Error())
+		writeServiceError(w, err)
 		return
 	}
 	if resp.ReservationUID == "" {
 		WriteError(w, http.StatusNotFound, "not found")
 		return
 	}
+	WriteStaleHeaders(w, stale)
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) GetReservationRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	username := getUsername(r)
+	if username == "" {
+		WriteError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	requestUID := last(r.URL.Path)
+	if requestUID == "" {
+		WriteError(w, http.StatusBadRequest, "invalid request uid")
+		return
+	}
+
+	resp, err := h.svc.GetReservationRequest(r.Context(), username, requestUID)
+	if err != nil {
+		if errors.Is(err, service.ErrForbidden) {
+			WriteError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if resp.RequestUID == "" {
+		WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
 	WriteJSON(w, http.StatusOK, resp)
 }
 
@@ -174,11 +307,15 @@ func (h *Handler) CancelReservation(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.svc.CancelReservation(r.Context(), username, reservationUID); err != nil {
+		if errors.Is(err, service.ErrServiceUnavailable) {
+			WriteError(w, http.StatusServiceUnavailable, "Reservation Service unavailable")
+			return
+		}
 		if err.Error() == "forbidden" {
 			WriteError(w, http.StatusForbidden, "forbidden")
 			return
 		}
-		WriteError(w, http.StatusInternalServerError, err.Error())
+		writeServiceError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -196,7 +333,31 @@ func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.svc.Me(r.Context(), username)
+	ctx, stale := service.WithStaleness(r.Context())
+	resp, err := h.svc.Me(ctx, username)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	WriteStaleHeaders(w, stale)
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) Breakers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	WriteJSON(w, http.StatusOK, h.svc.Breakers(r.Context()))
+}
+
+func (h *Handler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	resp, err := h.svc.ListDeadLetters(r.Context())
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -204,15 +365,71 @@ func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, resp)
 }
 
-func parseIntOrDefault(raw string, def int) int {
+func (h *Handler) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/replay")
+	if path == r.URL.Path {
+		WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
+	id, err := strconv.ParseInt(last(path), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	if err := h.svc.ReplayDeadLetter(r.Context(), id); err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			WriteError(w, http.StatusNotFound, "not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseHotelFilter reads a hotel filter from the query. Only numbers that do
+// not parse are rejected here; ranges and sort keys are checked by
+// reservation-service.
+func parseHotelFilter(q url.Values) (model.HotelFilter, error) {
+	f := model.HotelFilter{
+		Country: q.Get("country"),
+		City:    q.Get("city"),
+		Query:   q.Get("query"),
+		Sort:    q.Get("sort"),
+	}
+	for key, dst := range map[string]*int{"minStars": &f.MinStars, "minPrice": &f.MinPrice, "maxPrice": &f.MaxPrice} {
+		raw := q.Get(key)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return f, fmt.Errorf("%w: %s must be an integer", service.ErrInvalidFilter, key)
+		}
+		*dst = v
+	}
+	return f, nil
+}
+
+// parseFilterInt reads an optional integer query parameter of a filter. An
+// absent parameter yields def; a malformed one or one below min makes the
+// filter invalid rather than being ignored.
+func parseFilterInt(q url.Values, key string, def, min int) (int, error) {
+	raw := q.Get(key)
 	if raw == "" {
-		return def
+		return def, nil
 	}
 	v, err := strconv.Atoi(raw)
-	if err != nil || v <= 0 {
-		return def
+	if err != nil || v < min {
+		return 0, fmt.Errorf("%w: %s must be an integer not below %d", service.ErrInvalidFilter, key, min)
 	}
-	return v
+	return v, nil
 }
 
 func last(path string) string {
@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/coalesce"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/service"
 )
 
 type fakeGateway struct {
@@ -19,40 +24,89 @@ type fakeGateway struct {
 	me                model.MeResponse
 	getReservationRes model.ReservationShort
 	getReservationErr error
+	deadLetters       []model.SagaTask
+	replayErr         error
+	replayedID        int64
+	request           model.ReservationRequest
+	requestErr        error
+	createErr         error
+	cancelErr         error
+	idempotencyKey    string
+	breakers          []circuitbreaker.Snapshot
+	bulkheads         []bulkhead.Snapshot
+	hotelsErr         error
+	readiness         model.HealthReport
+	coalescing        []coalesce.Stats
+	filter            model.ReservationFilter
+	total             int
+	listErr           error
+	hotelFilter       model.HotelFilter
 }
 
 func (f *fakeGateway) Health(_ context.Context) error {
 	return f.healthErr
 }
 
-func (f *fakeGateway) ListHotels(_ context.Context, page, size int) (model.HotelsPage, error) {
-	return f.hotelsPage, nil
+func (f *fakeGateway) Coalescing(_ context.Context) []coalesce.Stats {
+	return f.coalescing
 }
 
-func (f *fakeGateway) GetLoyalty(username string) (model.Loyalty, error) {
+func (f *fakeGateway) Readiness(_ context.Context) model.HealthReport {
+	return f.readiness
+}
+
+func (f *fakeGateway) ListHotels(_ context.Context, page, size int, filter model.HotelFilter) (model.HotelsPage, error) {
+	f.hotelFilter = filter
+	return f.hotelsPage, f.hotelsErr
+}
+
+func (f *fakeGateway) GetLoyalty(_ context.Context, username string) (model.Loyalty, error) {
 	return f.loyalty, nil
 }
 
-func (f *fakeGateway) ListUserReservations(_ context.Context, username string) ([]model.ReservationShort, error) {
-	return f.reservations, nil
+func (f *fakeGateway) ListUserReservations(_ context.Context, username string, filter model.ReservationFilter) ([]model.ReservationShort, int, error) {
+	f.filter = filter
+	return f.reservations, f.total, f.listErr
 }
 
 func (f *fakeGateway) GetReservation(_ context.Context, username, reservationUID string) (model.ReservationShort, error) {
 	return f.getReservationRes, f.getReservationErr
 }
 
-func (f *fakeGateway) CreateReservation(_ context.Context, username, hotelUID, startDateStr, endDateStr string) (model.ReservationCreateResponse, error) {
-	return model.ReservationCreateResponse{}, nil
+func (f *fakeGateway) CreateReservation(_ context.Context, username, hotelUID, startDateStr, endDateStr, idempotencyKey string) (model.ReservationCreateResponse, error) {
+	f.idempotencyKey = idempotencyKey
+	return model.ReservationCreateResponse{}, f.createErr
 }
 
 func (f *fakeGateway) CancelReservation(_ context.Context, username, reservationUID string) error {
-	return nil
+	return f.cancelErr
 }
 
 func (f *fakeGateway) Me(_ context.Context, username string) (model.MeResponse, error) {
 	return f.me, nil
 }
 
+func (f *fakeGateway) ListDeadLetters(_ context.Context) ([]model.SagaTask, error) {
+	return f.deadLetters, nil
+}
+
+func (f *fakeGateway) ReplayDeadLetter(_ context.Context, id int64) error {
+	f.replayedID = id
+	return f.replayErr
+}
+
+func (f *fakeGateway) GetReservationRequest(_ context.Context, username, requestUID string) (model.ReservationRequest, error) {
+	return f.request, f.requestErr
+}
+
+func (f *fakeGateway) Breakers(_ context.Context) []circuitbreaker.Snapshot {
+	return f.breakers
+}
+
+func (f *fakeGateway) Bulkheads(_ context.Context) []bulkhead.Snapshot {
+	return f.bulkheads
+}
+
 func decodeJSONBody(t *testing.T, rr *httptest.ResponseRecorder, dst interface{}) {
 	t.Helper()
 	if err := json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(dst); err != nil {
@@ -202,3 +256,359 @@ func TestMe_OK(t *testing.T) {
 		t.Fatalf("unexpected fullAddress: %s", resp.Reservations[0].Hotel.FullAddress)
 	}
 }
+
+func TestReplayDeadLetter_OK(t *testing.T) {
+	fake := &fakeGateway{}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodPost, "/manage/saga/dead-letters/42/replay", nil)
+	rr := httptest.NewRecorder()
+
+	h.ReplayDeadLetter(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if fake.replayedID != 42 {
+		t.Fatalf("expected task 42 to be replayed, got %d", fake.replayedID)
+	}
+}
+
+func TestReplayDeadLetter_NotFound(t *testing.T) {
+	fake := &fakeGateway{replayErr: service.ErrTaskNotFound}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodPost, "/manage/saga/dead-letters/7/replay", nil)
+	rr := httptest.NewRecorder()
+
+	h.ReplayDeadLetter(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestGetReservationRequest_Completed(t *testing.T) {
+	fake := &fakeGateway{
+		request: model.ReservationRequest{
+			RequestUID:     "5b1b2a3c-8d4e-4f60-9a7b-1c2d3e4f5a6b",
+			HotelUID:       "049161bb-badd-4fa8-9d90-87c9a82b0668",
+			Status:         model.RequestCompleted,
+			ReservationUID: "e2866665-68f0-464b-802f-3a6eae827895",
+		},
+	}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reservations/requests/5b1b2a3c-8d4e-4f60-9a7b-1c2d3e4f5a6b", nil)
+	req.Header.Set("X-User-Name", "Test Max")
+	rr := httptest.NewRecorder()
+
+	h.GetReservationRequest(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var resp model.ReservationRequest
+	decodeJSONBody(t, rr, &resp)
+
+	if resp.Status != model.RequestCompleted || resp.ReservationUID != fake.request.ReservationUID {
+		t.Fatalf("unexpected request status: %+v", resp)
+	}
+}
+
+func TestGetReservationRequest_Forbidden(t *testing.T) {
+	fake := &fakeGateway{requestErr: service.ErrForbidden}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reservations/requests/5b1b2a3c-8d4e-4f60-9a7b-1c2d3e4f5a6b", nil)
+	req.Header.Set("X-User-Name", "Someone Else")
+	rr := httptest.NewRecorder()
+
+	h.GetReservationRequest(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+}
+
+func TestCreateReservation_PassesIdempotencyKey(t *testing.T) {
+	fake := &fakeGateway{}
+	h := NewHandler(fake)
+
+	body := `{"hotelUid":"049161bb-badd-4fa8-9d90-87c9a82b0668","startDate":"2021-10-08","endDate":"2021-10-11"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reservations", bytes.NewBufferString(body))
+	req.Header.Set("X-User-Name", "Test Max")
+	req.Header.Set("Idempotency-Key", "booking-1")
+	rr := httptest.NewRecorder()
+
+	h.CreateReservation(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if fake.idempotencyKey != "booking-1" {
+		t.Fatalf("expected idempotency key to be passed, got %q", fake.idempotencyKey)
+	}
+}
+
+func TestCreateReservation_IdempotencyKeyReused(t *testing.T) {
+	fake := &fakeGateway{createErr: service.ErrIdempotencyKeyReused}
+	h := NewHandler(fake)
+
+	body := `{"hotelUid":"049161bb-badd-4fa8-9d90-87c9a82b0668","startDate":"2021-10-08","endDate":"2021-10-12"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reservations", bytes.NewBufferString(body))
+	req.Header.Set("X-User-Name", "Test Max")
+	req.Header.Set("Idempotency-Key", "booking-1")
+	rr := httptest.NewRecorder()
+
+	h.CreateReservation(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+}
+
+func TestCancelReservation_ServiceUnavailable(t *testing.T) {
+	fake := &fakeGateway{cancelErr: service.ErrServiceUnavailable}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/reservations/8d2e6e7c-4b7b-4b7a-9d1e-2b9a3c4d5e6f", nil)
+	req.Header.Set("X-User-Name", "Test Max")
+	rr := httptest.NewRecorder()
+
+	h.CancelReservation(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+}
+
+func TestHotels_OverloadedIs503(t *testing.T) {
+	fake := &fakeGateway{hotelsErr: fmt.Errorf("list hotels: %w", service.ErrOverloaded)}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hotels", nil)
+	rr := httptest.NewRecorder()
+
+	h.Hotels(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+}
+
+func TestHotels_PassesFilter(t *testing.T) {
+	fake := &fakeGateway{}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hotels?country=Russia&city=Moscow&minStars=4&maxPrice=5000&query=ararat&sort=-price", nil)
+	rr := httptest.NewRecorder()
+
+	h.Hotels(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	want := model.HotelFilter{Country: "Russia", City: "Moscow", Query: "ararat", MinStars: 4, MaxPrice: 5000, Sort: "-price"}
+	if fake.hotelFilter != want {
+		t.Fatalf("unexpected filter: %+v", fake.hotelFilter)
+	}
+}
+
+func TestHotels_MalformedNumbersAre400(t *testing.T) {
+	for _, query := range []string{"minStars=five", "maxPrice=1e3", "page=abc", "size=0"} {
+		fake := &fakeGateway{}
+		h := NewHandler(fake)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/hotels?"+query, nil)
+		rr := httptest.NewRecorder()
+
+		h.Hotels(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHotels_LeavesRangesToReservationService(t *testing.T) {
+	fake := &fakeGateway{}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hotels?minStars=6&minPrice=-1", nil)
+	rr := httptest.NewRecorder()
+
+	h.Hotels(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the filter to be passed on, got %d", rr.Code)
+	}
+	if fake.hotelFilter.MinStars != 6 || fake.hotelFilter.MinPrice != -1 {
+		t.Fatalf("unexpected filter: %+v", fake.hotelFilter)
+	}
+}
+
+func TestHotels_InvalidFilterIs400(t *testing.T) {
+	fake := &fakeGateway{hotelsErr: service.ErrInvalidFilter}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hotels?sort=rating", nil)
+	rr := httptest.NewRecorder()
+
+	h.Hotels(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestListReservations_PassesFilterAndTotal(t *testing.T) {
+	fake := &fakeGateway{
+		reservations: []model.ReservationShort{{ReservationUID: "r1"}},
+		total:        7,
+	}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reservations?status=PAID&when=upcoming&sort=-startDate&page=2&size=1", nil)
+	req.Header.Set("X-User-Name", "Test Max")
+	rr := httptest.NewRecorder()
+
+	h.ListReservations(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	want := model.ReservationFilter{Status: "PAID", When: "upcoming", Sort: "-startDate", Page: 2, Size: 1}
+	if fake.filter != want {
+		t.Fatalf("unexpected filter: %+v", fake.filter)
+	}
+	if got := rr.Header().Get(TotalCountHeader); got != "7" {
+		t.Fatalf("expected total count 7, got %q", got)
+	}
+
+	var resp []model.ReservationShort
+	decodeJSONBody(t, rr, &resp)
+	if len(resp) != 1 {
+		t.Fatalf("expected the body to stay an array, got %+v", resp)
+	}
+}
+
+func TestListReservations_BadSizeIs400(t *testing.T) {
+	for _, query := range []string{"size=-1", "size=ten", "page=0"} {
+		fake := &fakeGateway{}
+		h := NewHandler(fake)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/reservations?"+query, nil)
+		req.Header.Set("X-User-Name", "Test Max")
+		rr := httptest.NewRecorder()
+
+		h.ListReservations(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestListReservations_InvalidFilterIs400(t *testing.T) {
+	fake := &fakeGateway{listErr: service.ErrInvalidFilter}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reservations?status=LOST", nil)
+	req.Header.Set("X-User-Name", "Test Max")
+	rr := httptest.NewRecorder()
+
+	h.ListReservations(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestHealth_ReportsBulkheads(t *testing.T) {
+	fake := &fakeGateway{
+		bulkheads: []bulkhead.Snapshot{{Name: "payment-service", Limit: 4, InFlight: 3, Saturation: 0.75}},
+	}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/manage/health", nil)
+	rr := httptest.NewRecorder()
+
+	h.Health(rr, req)
+
+	var resp struct {
+		Status    string              `json:"status"`
+		Bulkheads []bulkhead.Snapshot `json:"bulkheads"`
+	}
+	decodeJSONBody(t, rr, &resp)
+
+	if resp.Status != "ok" || len(resp.Bulkheads) != 1 || resp.Bulkheads[0].Saturation != 0.75 {
+		t.Fatalf("unexpected health response: %+v", resp)
+	}
+}
+
+func TestHealth_DeepReportsDependencies(t *testing.T) {
+	fake := &fakeGateway{
+		readiness: model.HealthReport{
+			Status: "degraded",
+			Dependencies: []model.DependencyHealth{
+				{Name: "payment-service", Status: "down", Error: "connection refused", Breakers: []model.BreakerState{}},
+			},
+		},
+	}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/manage/health?deep=true", nil)
+	rr := httptest.NewRecorder()
+
+	h.Health(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a degraded gateway, got %d", rr.Code)
+	}
+	var resp model.HealthReport
+	decodeJSONBody(t, rr, &resp)
+	if resp.Status != "degraded" || len(resp.Dependencies) != 1 || resp.Dependencies[0].Status != "down" {
+		t.Fatalf("unexpected deep health response: %+v", resp)
+	}
+}
+
+func TestReady_DownIs503(t *testing.T) {
+	fake := &fakeGateway{readiness: model.HealthReport{Status: "down"}}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/manage/ready", nil)
+	rr := httptest.NewRecorder()
+
+	h.Ready(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+}
+
+func TestBreakers_OK(t *testing.T) {
+	fake := &fakeGateway{
+		breakers: []circuitbreaker.Snapshot{
+			{Name: "reservation-service/getHotel", State: circuitbreaker.Open, FailureRate: 0.6, WindowSize: 10},
+		},
+	}
+	h := NewHandler(fake)
+
+	req := httptest.NewRequest(http.MethodGet, "/manage/breakers", nil)
+	rr := httptest.NewRecorder()
+
+	h.Breakers(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var resp []map[string]interface{}
+	decodeJSONBody(t, rr, &resp)
+
+	if len(resp) != 1 || resp[0]["state"] != "OPEN" || resp[0]["name"] != "reservation-service/getHotel" {
+		t.Fatalf("unexpected breakers response: %+v", resp)
+	}
+}
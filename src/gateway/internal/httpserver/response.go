@@ -3,8 +3,16 @@ package httpserver
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/service"
 )
 
+// TotalCountHeader carries the number of items matching a paged list, of
+// which the body holds one page.
+const TotalCountHeader = "X-Total-Count"
+
 func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -20,3 +28,14 @@ func WriteError(w http.ResponseWriter, status int, msg string) {
 		"message": msg,
 	})
 }
+
+// WriteStaleHeaders marks a response that was served from the cache with a
+// Warning and the Age in seconds of its oldest cached part.
+func WriteStaleHeaders(w http.ResponseWriter, st *service.Staleness) {
+	stale, since := st.Stale()
+	if !stale {
+		return
+	}
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(since).Seconds())))
+}
@@ -12,6 +12,10 @@ func NewRouter(s service.Gateway) http.Handler {
 	h := NewHandler(s)
 
 	mux.HandleFunc("/manage/health", h.Health)
+	mux.HandleFunc("/manage/ready", h.Ready)
+	mux.HandleFunc("/manage/breakers", h.Breakers)
+	mux.HandleFunc("/manage/saga/dead-letters", h.DeadLetters)
+	mux.HandleFunc("/manage/saga/dead-letters/", h.ReplayDeadLetter)
 
 	mux.HandleFunc("/api/v1/hotels", h.Hotels)
 	mux.HandleFunc("/api/v1/loyalty", h.Loyalty)
@@ -37,6 +41,7 @@ func NewRouter(s service.Gateway) http.Handler {
 		}
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	})
+	mux.HandleFunc("/api/v1/reservations/requests/", h.GetReservationRequest)
 	mux.HandleFunc("/api/v1/me", h.Me)
 
 	return mux
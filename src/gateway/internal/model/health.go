@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// HealthReport is the deep health of the gateway: the state of every
+// downstream it depends on and an overall status derived from them.
+type HealthReport struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+}
+
+type DependencyHealth struct {
+	Name      string         `json:"name"`
+	Status    string         `json:"status"`
+	LatencyMs int64          `json:"latencyMs"`
+	CheckedAt *time.Time     `json:"checkedAt,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Breakers  []BreakerState `json:"breakers"`
+}
+
+type BreakerState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
@@ -17,3 +17,16 @@ type HotelsPage struct {
 	TotalElements int     `json:"totalElements"`
 	Items         []Hotel `json:"items"`
 }
+
+// HotelFilter narrows and orders a hotel listing; reservation-service
+// validates it. Zero fields do not filter. Sort names price, stars or name,
+// with a leading "-" for descending order.
+type HotelFilter struct {
+	Country  string
+	City     string
+	Query    string
+	MinStars int
+	MinPrice int
+	MaxPrice int
+	Sort     string
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyRecord tracks one reservation intent: the saga steps already
+// done for it and, once answered, the response returned to the client.
+type IdempotencyRecord struct {
+	ID             int64
+	Username       string
+	Key            string
+	RequestHash    string
+	PaymentKey     string
+	PaymentUID     string
+	ReservationUID string
+	Response       json.RawMessage
+	UpdatedAt      time.Time
+}
@@ -1,7 +1,10 @@
 package model
 
+// MeResponse is the profile of a user. Degraded names the parts, "loyalty"
+// for now, that could not be looked up and are left empty.
 type MeResponse struct {
 	Username     string             `json:"username"`
 	Loyalty      Loyalty            `json:"loyalty"`
 	Reservations []ReservationShort `json:"reservations"`
+	Degraded     []string           `json:"degraded,omitempty"`
 }
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+const (
+	RequestPending   = "PENDING"
+	RequestCompleted = "COMPLETED"
+	RequestFailed    = "FAILED"
+)
+
+type ReservationRequest struct {
+	RequestUID     string    `json:"requestUid"`
+	Username       string    `json:"-"`
+	HotelUID       string    `json:"hotelUid"`
+	StartDate      string    `json:"startDate"`
+	EndDate        string    `json:"endDate"`
+	Status         string    `json:"status"`
+	ReservationUID string    `json:"reservationUid,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
@@ -2,13 +2,30 @@ package model
 
 import "time"
 
+// ReservationShort is a reservation as shown to the user. Degraded names the
+// parts, "hotel" or "payment", that could not be looked up and hold only
+// their UID.
 type ReservationShort struct {
-	ReservationUID string  `json:"reservationUid"`
-	Hotel          Hotel   `json:"hotel"`
-	StartDate      string  `json:"startDate"`
-	EndDate        string  `json:"endDate"`
-	Status         string  `json:"status"`
-	Payment        Payment `json:"payment"`
+	ReservationUID string   `json:"reservationUid"`
+	Hotel          Hotel    `json:"hotel"`
+	StartDate      string   `json:"startDate"`
+	EndDate        string   `json:"endDate"`
+	Status         string   `json:"status"`
+	Payment        Payment  `json:"payment"`
+	Degraded       []string `json:"degraded,omitempty"`
+}
+
+// ReservationFilter selects, orders and pages the reservations of a user.
+// The fields are passed on to reservation-service as given in the query,
+// and it is reservation-service that validates them.
+type ReservationFilter struct {
+	Status string
+	From   string
+	To     string
+	When   string
+	Sort   string
+	Page   int
+	Size   int
 }
 
 type ReservationInternal struct {
@@ -35,6 +52,7 @@ type ReservationFull struct {
 
 type ReservationCreateResponse struct {
 	ReservationUID string                `json:"reservationUid"`
+	RequestUID     string                `json:"requestUid,omitempty"`
 	HotelUID       string                `json:"hotelUid"`
 	StartDate      string                `json:"startDate"`
 	EndDate        string                `json:"endDate"`
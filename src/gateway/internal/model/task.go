@@ -0,0 +1,43 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	TaskLoyaltyIncrement  = "LOYALTY_INCREMENT"
+	TaskLoyaltyDecrement  = "LOYALTY_DECREMENT"
+	TaskPaymentCancel     = "PAYMENT_CANCEL"
+	TaskReservationCreate = "RESERVATION_CREATE"
+)
+
+type SagaTask struct {
+	ID            int64           `json:"id"`
+	Kind          string          `json:"kind"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	Postponements int             `json:"postponements"`
+	LastError     string          `json:"lastError,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+type LoyaltyTaskPayload struct {
+	Username       string `json:"username"`
+	ReservationUID string `json:"reservationUid,omitempty"`
+}
+
+type PaymentTaskPayload struct {
+	PaymentUID string `json:"paymentUid"`
+}
+
+type ReservationTaskPayload struct {
+	RequestUID     string `json:"requestUid,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	Username       string `json:"username"`
+	HotelUID       string `json:"hotelUid"`
+	StartDate      string `json:"startDate"`
+	EndDate        string `json:"endDate"`
+}
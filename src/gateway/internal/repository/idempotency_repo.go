@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+const idempotencyColumns = `
+	id, username, idem_key, request_hash, COALESCE(payment_key, ''),
+	COALESCE(payment_uid::text, ''), COALESCE(reservation_uid::text, ''),
+	response, updated_at`
+
+func scanIdempotency(row *sql.Row) (model.IdempotencyRecord, error) {
+	var rec model.IdempotencyRecord
+	err := row.Scan(
+		&rec.ID,
+		&rec.Username,
+		&rec.Key,
+		&rec.RequestHash,
+		&rec.PaymentKey,
+		&rec.PaymentUID,
+		&rec.ReservationUID,
+		(*[]byte)(&rec.Response),
+		&rec.UpdatedAt,
+	)
+	return rec, err
+}
+
+// Acquire stores a new key or loads the existing one. The boolean is true
+// when the key was created by this call.
+func (r *IdempotencyRepository) Acquire(ctx context.Context, username, key, requestHash string) (model.IdempotencyRecord, bool, error) {
+	rec, err := scanIdempotency(r.db.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys (username, idem_key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (username, idem_key) DO NOTHING
+		RETURNING`+idempotencyColumns,
+		username, key, requestHash,
+	))
+	if err == nil {
+		return rec, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return model.IdempotencyRecord{}, false, fmt.Errorf("insert idempotency key: %w", err)
+	}
+
+	rec, err = r.Get(ctx, username, key)
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	return rec, false, nil
+}
+
+func (r *IdempotencyRepository) Get(ctx context.Context, username, key string) (model.IdempotencyRecord, error) {
+	rec, err := scanIdempotency(r.db.QueryRowContext(ctx,
+		`SELECT`+idempotencyColumns+` FROM idempotency_keys WHERE username = $1 AND idem_key = $2`,
+		username, key,
+	))
+	if err == sql.ErrNoRows {
+		return model.IdempotencyRecord{}, nil
+	}
+	if err != nil {
+		return model.IdempotencyRecord{}, fmt.Errorf("select idempotency key: %w", err)
+	}
+	return rec, nil
+}
+
+// TakeOver claims a key whose previous owner stopped answering before
+// updatedBefore. It reports false if another request holds the key.
+func (r *IdempotencyRepository) TakeOver(ctx context.Context, id int64, updatedBefore time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET updated_at = now()
+		 WHERE id = $1 AND response IS NULL AND updated_at < $2`,
+		id, updatedBefore,
+	)
+	if err != nil {
+		return false, fmt.Errorf("take over idempotency key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("take over idempotency key: %w", err)
+	}
+	return n > 0, nil
+}
+
+// SetPayment records the operation key sent to payment-service and, once
+// known, the uid of the created payment. Empty values clear them.
+func (r *IdempotencyRepository) SetPayment(ctx context.Context, id int64, paymentKey, paymentUID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys
+		 SET payment_key = NULLIF($2, ''), payment_uid = NULLIF($3, '')::uuid, updated_at = now()
+		 WHERE id = $1`,
+		id, paymentKey, paymentUID,
+	)
+	if err != nil {
+		return fmt.Errorf("update idempotency payment: %w", err)
+	}
+	return nil
+}
+
+func (r *IdempotencyRepository) SetReservation(ctx context.Context, id int64, reservationUID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET reservation_uid = NULLIF($2, '')::uuid, updated_at = now() WHERE id = $1`,
+		id, reservationUID,
+	)
+	if err != nil {
+		return fmt.Errorf("update idempotency reservation: %w", err)
+	}
+	return nil
+}
+
+func (r *IdempotencyRepository) SaveResponse(ctx context.Context, id int64, response interface{}) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("marshal idempotency response: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET response = $2, updated_at = now() WHERE id = $1`,
+		id, data,
+	)
+	if err != nil {
+		return fmt.Errorf("save idempotency response: %w", err)
+	}
+	return nil
+}
+
+// ClearResponse drops the response stored for a key whose queued saga gave
+// up, and backdates the key so that the next request with it resumes the saga
+// at once instead of waiting out the lock.
+func (r *IdempotencyRepository) ClearResponse(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET response = NULL, updated_at = to_timestamp(0) WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("clear idempotency response: %w", err)
+	}
+	return nil
+}
+
+// Delete releases a key whose request failed without side effects, so the
+// client may retry it.
+func (r *IdempotencyRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+type RequestRepository struct {
+	db *sql.DB
+}
+
+func NewRequestRepository(db *sql.DB) *RequestRepository {
+	return &RequestRepository{db: db}
+}
+
+func (r *RequestRepository) Create(ctx context.Context, req model.ReservationRequest) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO reservation_requests (request_uid, username, hotel_uid, start_date, end_date, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		req.RequestUID,
+		req.Username,
+		req.HotelUID,
+		req.StartDate,
+		req.EndDate,
+		req.Status,
+	)
+	if err != nil {
+		return fmt.Errorf("insert reservation request: %w", err)
+	}
+	return nil
+}
+
+func (r *RequestRepository) Get(ctx context.Context, requestUID string) (model.ReservationRequest, error) {
+	var req model.ReservationRequest
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT request_uid, username, hotel_uid, start_date, end_date, status,
+		       COALESCE(reservation_uid::text, ''), COALESCE(last_error, ''), created_at, updated_at
+		FROM reservation_requests
+		WHERE request_uid = $1
+	`, requestUID).Scan(
+		&req.RequestUID,
+		&req.Username,
+		&req.HotelUID,
+		&req.StartDate,
+		&req.EndDate,
+		&req.Status,
+		&req.ReservationUID,
+		&req.Error,
+		&req.CreatedAt,
+		&req.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return model.ReservationRequest{}, nil
+	}
+	if err != nil {
+		return model.ReservationRequest{}, fmt.Errorf("select reservation request: %w", err)
+	}
+
+	return req, nil
+}
+
+func (r *RequestRepository) Complete(ctx context.Context, requestUID, reservationUID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reservation_requests
+		SET status = 'COMPLETED', reservation_uid = $2, last_error = NULL, updated_at = now()
+		WHERE request_uid = $1
+	`, requestUID, reservationUID)
+	if err != nil {
+		return fmt.Errorf("complete reservation request: %w", err)
+	}
+	return nil
+}
+
+func (r *RequestRepository) Fail(ctx context.Context, requestUID, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reservation_requests
+		SET status = 'FAILED', last_error = $2, updated_at = now()
+		WHERE request_uid = $1
+	`, requestUID, reason)
+	if err != nil {
+		return fmt.Errorf("fail reservation request: %w", err)
+	}
+	return nil
+}
+
+// Reopen marks a failed request PENDING again when its task is replayed.
+func (r *RequestRepository) Reopen(ctx context.Context, requestUID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reservation_requests
+		SET status = 'PENDING', updated_at = now()
+		WHERE request_uid = $1 AND status = 'FAILED'
+	`, requestUID)
+	if err != nil {
+		return fmt.Errorf("reopen reservation request: %w", err)
+	}
+	return nil
+}
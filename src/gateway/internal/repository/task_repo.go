@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+type TaskRepository struct {
+	db *sql.DB
+}
+
+func NewTaskRepository(db *sql.DB) *TaskRepository {
+	return &TaskRepository{db: db}
+}
+
+func (r *TaskRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *TaskRepository) Enqueue(ctx context.Context, kind string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal task payload: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO saga_tasks (kind, payload) VALUES ($1, $2)`,
+		kind, data,
+	)
+	if err != nil {
+		return fmt.Errorf("insert saga task: %w", err)
+	}
+	return nil
+}
+
+// Claim locks the oldest due task, marks it RUNNING and counts the attempt.
+// The boolean is false when no task is due.
+func (r *TaskRepository) Claim(ctx context.Context) (model.SagaTask, bool, error) {
+	var t model.SagaTask
+
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE saga_tasks
+		SET status = 'RUNNING', attempts = attempts + 1, updated_at = now()
+		WHERE id = (
+			SELECT id FROM saga_tasks
+			WHERE status = 'PENDING' AND next_run_at <= now()
+			ORDER BY next_run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, status, attempts, postponements, created_at, updated_at
+	`).Scan(&t.ID, &t.Kind, (*[]byte)(&t.Payload), &t.Status, &t.Attempts, &t.Postponements, &t.CreatedAt, &t.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return model.SagaTask{}, false, nil
+	}
+	if err != nil {
+		return model.SagaTask{}, false, fmt.Errorf("claim saga task: %w", err)
+	}
+
+	return t, true, nil
+}
+
+func (r *TaskRepository) MarkDone(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE saga_tasks SET status = 'DONE', last_error = NULL, updated_at = now() WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("mark saga task done: %w", err)
+	}
+	return nil
+}
+
+// Reschedule puts a failed task back into the queue to run again at runAt.
+func (r *TaskRepository) Reschedule(ctx context.Context, id int64, reason string, runAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE saga_tasks
+		 SET status = 'PENDING', last_error = $2, next_run_at = $3, updated_at = now()
+		 WHERE id = $1`,
+		id, reason, runAt,
+	)
+	if err != nil {
+		return fmt.Errorf("reschedule saga task: %w", err)
+	}
+	return nil
+}
+
+// Postpone puts a claimed task back without counting the claim as an
+// attempt, for failures that happened before the downstream was called. The
+// postponement is counted instead.
+func (r *TaskRepository) Postpone(ctx context.Context, id int64, reason string, runAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE saga_tasks
+		 SET status = 'PENDING', attempts = GREATEST(attempts - 1, 0), postponements = postponements + 1,
+		     last_error = $2, next_run_at = $3, updated_at = now()
+		 WHERE id = $1`,
+		id, reason, runAt,
+	)
+	if err != nil {
+		return fmt.Errorf("postpone saga task: %w", err)
+	}
+	return nil
+}
+
+// MarkDead moves a task to the dead-letter list, where it stays until an
+// operator replays it.
+func (r *TaskRepository) MarkDead(ctx context.Context, id int64, reason string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE saga_tasks SET status = 'DEAD', last_error = $2, updated_at = now() WHERE id = $1`,
+		id, reason,
+	)
+	if err != nil {
+		return fmt.Errorf("mark saga task dead: %w", err)
+	}
+	return nil
+}
+
+func (r *TaskRepository) ListDead(ctx context.Context) ([]model.SagaTask, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, kind, payload, status, attempts, postponements, COALESCE(last_error, ''), created_at, updated_at
+		FROM saga_tasks
+		WHERE status = 'DEAD'
+		ORDER BY updated_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list dead saga tasks: %w", err)
+	}
+	defer rows.Close()
+
+	result := []model.SagaTask{}
+	for rows.Next() {
+		var t model.SagaTask
+		if err := rows.Scan(
+			&t.ID,
+			&t.Kind,
+			(*[]byte)(&t.Payload),
+			&t.Status,
+			&t.Attempts,
+			&t.Postponements,
+			&t.LastError,
+			&t.CreatedAt,
+			&t.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan saga task: %w", err)
+		}
+		result = append(result, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// Replay returns a dead task to the queue with a fresh attempt and
+// postponement budget. The
+// boolean is false when there is no dead task with that id.
+func (r *TaskRepository) Replay(ctx context.Context, id int64) (model.SagaTask, bool, error) {
+	var t model.SagaTask
+
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE saga_tasks
+		SET status = 'PENDING', attempts = 0, postponements = 0, next_run_at = now(), updated_at = now()
+		WHERE id = $1 AND status = 'DEAD'
+		RETURNING id, kind, payload, status, attempts, postponements, created_at, updated_at
+	`, id).Scan(&t.ID, &t.Kind, (*[]byte)(&t.Payload), &t.Status, &t.Attempts, &t.Postponements, &t.CreatedAt, &t.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return model.SagaTask{}, false, nil
+	}
+	if err != nil {
+		return model.SagaTask{}, false, fmt.Errorf("replay saga task: %w", err)
+	}
+
+	return t, true, nil
+}
+
+// RequeueRunning returns tasks left RUNNING by a previous process back to
+// PENDING so they are picked up again after a restart.
+func (r *TaskRepository) RequeueRunning(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE saga_tasks SET status = 'PENDING', updated_at = now() WHERE status = 'RUNNING'`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("requeue running saga tasks: %w", err)
+	}
+	return res.RowsAffected()
+}
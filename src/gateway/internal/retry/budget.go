@@ -0,0 +1,44 @@
+package retry
+
+import "sync"
+
+// Budget caps retries to a share of the calls made, so that retries cannot
+// multiply the load on a downstream that is already failing. Every call
+// deposits ratio tokens and every retry withdraws one; the balance never
+// exceeds burst, which is also where it starts.
+type Budget struct {
+	mu      sync.Mutex
+	ratio   float64
+	burst   float64
+	balance float64
+}
+
+func NewBudget(ratio, burst float64) *Budget {
+	return &Budget{
+		ratio:   ratio,
+		burst:   burst,
+		balance: burst,
+	}
+}
+
+func (b *Budget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.balance += b.ratio
+	if b.balance > b.burst {
+		b.balance = b.burst
+	}
+}
+
+// Withdraw reports whether a retry may be made and, if so, pays for it.
+func (b *Budget) Withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.balance < 1 {
+		return false
+	}
+	b.balance--
+	return true
+}
@@ -0,0 +1,48 @@
+package retry
+
+import (
+	"math/rand"
+	"time"
+)
+
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 8,
+		BaseDelay:   1 * time.Second,
+		MaxDelay:    5 * time.Minute,
+	}
+}
+
+// Backoff returns the delay before the next try after the given attempt
+// (1-based). The delay doubles with every attempt up to MaxDelay, and a
+// random half of it is jittered so that retries of many tasks spread out.
+// A policy without a BaseDelay retries at once.
+func (p Policy) Backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := p.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		if exp := p.BaseDelay << shift; exp > 0 && exp < p.MaxDelay {
+			d = exp
+		}
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Exhausted reports whether no attempts are left after the given one.
+func (p Policy) Exhausted(attempt int) bool {
+	return attempt >= p.MaxAttempts
+}
@@ -0,0 +1,64 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_GrowsAndCaps(t *testing.T) {
+	p := Policy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for attempt, want := range map[int]time.Duration{
+		1:  1 * time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		5:  10 * time.Second,
+		60: 10 * time.Second,
+	} {
+		for i := 0; i < 50; i++ {
+			d := p.Backoff(attempt)
+			if d < want/2 || d > want {
+				t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestBackoff_ZeroBaseDelayRetriesAtOnce(t *testing.T) {
+	p := Policy{MaxAttempts: 3, MaxDelay: 5 * time.Second}
+
+	if d := p.Backoff(1); d != 0 {
+		t.Fatalf("expected no delay without a base delay, got %v", d)
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	p := Policy{MaxAttempts: 3}
+
+	if p.Exhausted(2) {
+		t.Fatalf("expected attempts left after 2 of 3")
+	}
+	if !p.Exhausted(3) {
+		t.Fatalf("expected policy exhausted after 3 of 3")
+	}
+}
+
+func TestBudget_LimitsRetriesToRatio(t *testing.T) {
+	b := NewBudget(0.5, 2)
+
+	if !b.Withdraw() || !b.Withdraw() {
+		t.Fatalf("expected the initial burst to be available")
+	}
+	if b.Withdraw() {
+		t.Fatalf("expected the budget to be exhausted")
+	}
+
+	b.Deposit()
+	if b.Withdraw() {
+		t.Fatalf("expected half a token not to pay for a retry")
+	}
+	b.Deposit()
+	if !b.Withdraw() {
+		t.Fatalf("expected two calls to pay for one retry")
+	}
+}
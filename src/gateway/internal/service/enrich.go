@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+// Parts of a response that are left empty when their lookup fails.
+const (
+	DegradedHotel   = "hotel"
+	DegradedPayment = "payment"
+	DegradedLoyalty = "loyalty"
+)
+
+// enrich adds the hotel and payment to every reservation. Hotels and
+// payments are looked up in batches of at most clients.MaxBatchSize, up to
+// enrichLimit batches at a time, so a whole history takes two calls in the
+// usual case. A failed batch does not fail the call: its hotels or payments
+// keep only their UID and are listed in Degraded. The result keeps the
+// order of reservations.
+func (s *GatewayService) enrich(ctx context.Context, reservations []model.ReservationFull) ([]model.ReservationShort, error) {
+	var hotelUIDs, paymentUIDs []string
+	seen := make(map[string]bool)
+	for _, r := range reservations {
+		if !seen[r.HotelUID] {
+			seen[r.HotelUID] = true
+			hotelUIDs = append(hotelUIDs, r.HotelUID)
+		}
+		paymentUIDs = append(paymentUIDs, r.PaymentUID)
+	}
+
+	// A UID is present once its lookup succeeded. When nothing was found for
+	// it, it maps to an entry holding only the UID.
+	var mu sync.Mutex
+	hotels := make(map[string]model.Hotel)
+	payments := make(map[string]model.Payment)
+
+	f := newFanOut(ctx, s.enrichLimit)
+	for _, uids := range batches(hotelUIDs) {
+		uids := uids
+		f.Go(func(ctx context.Context) error {
+			found, err := s.getHotels(ctx, uids)
+			if err != nil {
+				log.Printf("enrich: %d hotels degraded: %v", len(uids), err)
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, uid := range uids {
+				hotels[uid] = model.Hotel{HotelUID: uid}
+				if h, ok := found[uid]; ok {
+					h.FullAddress = fmt.Sprintf("%s, %s, %s", h.Country, h.City, h.Address)
+					hotels[uid] = h
+				}
+			}
+			return nil
+		})
+	}
+	for _, uids := range batches(paymentUIDs) {
+		uids := uids
+		f.Go(func(ctx context.Context) error {
+			found, err := s.paymentClient.GetPayments(ctx, uids)
+			if err != nil {
+				log.Printf("enrich: %d payments degraded: %v", len(uids), err)
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, uid := range uids {
+				payments[uid] = model.Payment{PaymentUID: uid}
+				if p, ok := found[uid]; ok {
+					payments[uid] = p
+				}
+			}
+			return nil
+		})
+	}
+	if err := f.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := make([]model.ReservationShort, 0, len(reservations))
+	for _, r := range reservations {
+		item := model.ReservationShort{
+			ReservationUID: r.ReservationUID,
+			Hotel:          model.Hotel{HotelUID: r.HotelUID},
+			StartDate:      r.StartDate.Format("2006-01-02"),
+			EndDate:        r.EndDate.Format("2006-01-02"),
+			Status:         r.Status,
+			Payment:        model.Payment{PaymentUID: r.PaymentUID},
+		}
+		if h, ok := hotels[r.HotelUID]; ok {
+			item.Hotel = h
+		} else {
+			item.Degraded = append(item.Degraded, DegradedHotel)
+		}
+		if p, ok := payments[r.PaymentUID]; ok {
+			item.Payment = p
+		} else {
+			item.Degraded = append(item.Degraded, DegradedPayment)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// getHotels looks up a batch of hotels for display. While the breaker of
+// reservation-service is open the batch is served from the cache if every
+// hotel of it is there, and each of them is refreshed in the background.
+// Bookings go through fetchHotel instead, which shares in-flight calls but
+// never falls back to the cache, so they never price against stale data.
+func (s *GatewayService) getHotels(ctx context.Context, uids []string) (map[string]model.Hotel, error) {
+	found, err := s.hotelBatchCalls.Do(ctx, strings.Join(uids, ","), func(ctx context.Context) (map[string]model.Hotel, error) {
+		return s.reservationClient.GetHotels(ctx, uids)
+	})
+	if err == nil {
+		for uid, h := range found {
+			s.hotels.Set(uid, h)
+		}
+		return found, nil
+	}
+	if !errors.Is(err, clients.ErrCircuitOpen) {
+		return nil, err
+	}
+
+	cached := make(map[string]model.Hotel, len(uids))
+	for _, uid := range uids {
+		h, storedAt, ok := s.hotels.Get(uid)
+		if !ok {
+			return nil, err
+		}
+		cached[uid] = h
+		markStale(ctx, storedAt)
+	}
+	for _, uid := range uids {
+		uid := uid
+		s.hotels.Revalidate(uid, func() (model.Hotel, error) {
+			return s.fetchHotel(context.Background(), uid)
+		})
+	}
+	return cached, nil
+}
+
+func batches(uids []string) [][]string {
+	var result [][]string
+	for len(uids) > clients.MaxBatchSize {
+		result = append(result, uids[:clients.MaxBatchSize])
+		uids = uids[clients.MaxBatchSize:]
+	}
+	if len(uids) > 0 {
+		result = append(result, uids)
+	}
+	return result
+}
+
+// fanOut runs functions concurrently, at most limit at a time. The first
+// error cancels the context passed to the others and is returned by Wait.
+type fanOut struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	slots  chan struct{}
+	wg     sync.WaitGroup
+
+	once sync.Once
+	err  error
+}
+
+func newFanOut(ctx context.Context, limit int) *fanOut {
+	if limit < 1 {
+		limit = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &fanOut{
+		ctx:    ctx,
+		cancel: cancel,
+		slots:  make(chan struct{}, limit),
+	}
+}
+
+// Go starts fn once a slot is free. It blocks until then, and skips fn when
+// the context ends first.
+func (f *fanOut) Go(fn func(ctx context.Context) error) {
+	select {
+	case f.slots <- struct{}{}:
+	case <-f.ctx.Done():
+		f.fail(f.ctx.Err())
+		return
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer func() { <-f.slots }()
+
+		if err := fn(f.ctx); err != nil {
+			f.fail(err)
+		}
+	}()
+}
+
+func (f *fanOut) Wait() error {
+	f.wg.Wait()
+	f.cancel()
+	return f.err
+}
+
+func (f *fanOut) fail(err error) {
+	f.once.Do(func() {
+		f.err = err
+		f.cancel()
+	})
+}
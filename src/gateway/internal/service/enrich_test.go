@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/cache"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/coalesce"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+func TestEnrich_KeepsOrderAndBatchesLookups(t *testing.T) {
+	var calls int32
+	var hotelUIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/internal/hotels" {
+			hotelUIDs = strings.Split(r.URL.Query().Get("uids"), ",")
+			hotels := make(map[string]model.Hotel)
+			for _, uid := range hotelUIDs {
+				hotels[uid] = model.Hotel{HotelUID: uid, Name: "hotel " + uid}
+			}
+			json.NewEncoder(w).Encode(hotels)
+			return
+		}
+		var body struct {
+			UIDs []string `json:"uids"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		payments := make(map[string]model.Payment)
+		for _, uid := range body.UIDs {
+			payments[uid] = model.Payment{PaymentUID: uid}
+		}
+		json.NewEncoder(w).Encode(payments)
+	}))
+	defer srv.Close()
+
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy())
+	s := &GatewayService{
+		reservationClient: clients.NewReservationClient(srv.URL, clients.Timeouts{Default: time.Second}, clients.Retries{}, breakers, bulkhead.New(clients.ReservationService, 8, 0)),
+		paymentClient:     clients.NewPaymentClient(srv.URL, clients.Timeouts{Default: time.Second}, clients.Retries{}, breakers, bulkhead.New(clients.PaymentService, 8, 0)),
+		hotels:            cache.New[model.Hotel](cache.DefaultPolicy()),
+		hotelBatchCalls:   coalesce.New[map[string]model.Hotel]("getHotels"),
+		enrichLimit:       3,
+	}
+
+	reservations := []model.ReservationFull{
+		{ReservationUID: "r1", HotelUID: "h1", PaymentUID: "p1"},
+		{ReservationUID: "r2", HotelUID: "h2", PaymentUID: "p2"},
+		{ReservationUID: "r3", HotelUID: "h1", PaymentUID: "p3"},
+		{ReservationUID: "r4", HotelUID: "h2", PaymentUID: "p4"},
+	}
+	result, err := s.enrich(context.Background(), reservations)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, r := range reservations {
+		got := result[i]
+		if got.ReservationUID != r.ReservationUID || got.Hotel.HotelUID != r.HotelUID || got.Payment.PaymentUID != r.PaymentUID {
+			t.Fatalf("result %d out of order: %+v", i, got)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected one batch call per downstream, got %d", n)
+	}
+	if len(hotelUIDs) != 2 {
+		t.Fatalf("expected each hotel to be asked for once, got %v", hotelUIDs)
+	}
+}
+
+func TestEnrich_DegradesFailedPayments(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal/hotels" {
+			json.NewEncoder(w).Encode(map[string]model.Hotel{"h1": {HotelUID: "h1", City: "Moscow"}})
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy())
+	s := &GatewayService{
+		reservationClient: clients.NewReservationClient(srv.URL, clients.Timeouts{Default: time.Second}, clients.Retries{}, breakers, bulkhead.New(clients.ReservationService, 8, 0)),
+		paymentClient:     clients.NewPaymentClient(srv.URL, clients.Timeouts{Default: time.Second}, clients.Retries{}, breakers, bulkhead.New(clients.PaymentService, 8, 0)),
+		hotels:            cache.New[model.Hotel](cache.DefaultPolicy()),
+		hotelBatchCalls:   coalesce.New[map[string]model.Hotel]("getHotels"),
+		enrichLimit:       2,
+	}
+
+	result, err := s.enrich(context.Background(), []model.ReservationFull{{ReservationUID: "r1", HotelUID: "h1", PaymentUID: "p1"}})
+	if err != nil {
+		t.Fatalf("expected a failed payment lookup not to fail the call, got %v", err)
+	}
+
+	got := result[0]
+	if got.Hotel.City != "Moscow" {
+		t.Fatalf("expected the hotel to be filled in: %+v", got.Hotel)
+	}
+	if got.Payment != (model.Payment{PaymentUID: "p1"}) {
+		t.Fatalf("expected the payment to keep only its uid: %+v", got.Payment)
+	}
+	if len(got.Degraded) != 1 || got.Degraded[0] != DegradedPayment {
+		t.Fatalf("expected payment to be listed as degraded, got %v", got.Degraded)
+	}
+}
+
+func TestEnrich_KeepsUIDsNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal/hotels" {
+			json.NewEncoder(w).Encode(map[string]model.Hotel{})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]model.Payment{})
+	}))
+	defer srv.Close()
+
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy())
+	s := &GatewayService{
+		reservationClient: clients.NewReservationClient(srv.URL, clients.Timeouts{Default: time.Second}, clients.Retries{}, breakers, bulkhead.New(clients.ReservationService, 8, 0)),
+		paymentClient:     clients.NewPaymentClient(srv.URL, clients.Timeouts{Default: time.Second}, clients.Retries{}, breakers, bulkhead.New(clients.PaymentService, 8, 0)),
+		hotels:            cache.New[model.Hotel](cache.DefaultPolicy()),
+		hotelBatchCalls:   coalesce.New[map[string]model.Hotel]("getHotels"),
+		enrichLimit:       2,
+	}
+
+	result, err := s.enrich(context.Background(), []model.ReservationFull{{ReservationUID: "r1", HotelUID: "h1", PaymentUID: "p1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := result[0]
+	if got.Hotel != (model.Hotel{HotelUID: "h1"}) {
+		t.Fatalf("expected the hotel to keep only its uid: %+v", got.Hotel)
+	}
+	if got.Payment != (model.Payment{PaymentUID: "p1"}) {
+		t.Fatalf("expected the payment to keep only its uid: %+v", got.Payment)
+	}
+	if len(got.Degraded) != 0 {
+		t.Fatalf("expected lookups that succeeded not to be degraded, got %v", got.Degraded)
+	}
+}
+
+func TestFanOut_LimitsConcurrencyAndStopsOnError(t *testing.T) {
+	f := newFanOut(context.Background(), 2)
+
+	var running, peak int32
+	boom := errors.New("boom")
+	for i := 0; i < 6; i++ {
+		i := i
+		f.Go(func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			if i == 1 {
+				return boom
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+				return nil
+			}
+		})
+	}
+
+	if err := f.Wait(); !errors.Is(err, boom) {
+		t.Fatalf("expected the first error, got %v", err)
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Fatalf("expected at most 2 concurrent calls, got %d", p)
+	}
+}
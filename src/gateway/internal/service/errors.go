@@ -1,6 +1,19 @@
 package service
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+)
 
 var ErrHotelNotFound = errors.New("hotel not found")
 var ErrServiceUnavailable = errors.New("service unavailable")
+var ErrTaskNotFound = errors.New("saga task not found")
+var ErrForbidden = errors.New("forbidden")
+var ErrInvalidFilter = errors.New("invalid filter")
+var ErrInvalidReservation = errors.New("invalid reservation request")
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+var ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is in progress")
+
+// ErrOverloaded is returned when a downstream bulkhead has no free slot.
+var ErrOverloaded = bulkhead.ErrFull
@@ -4,102 +4,193 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/bulkhead"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/cache"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/coalesce"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/health"
 	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/repository"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/retry"
 )
 
 type Gateway interface {
 	Health(ctx context.Context) error
-	ListHotels(ctx context.Context, page, size int) (model.HotelsPage, error)
-	GetLoyalty(username string) (model.Loyalty, error)
-	ListUserReservations(ctx context.Context, username string) ([]model.ReservationShort, error)
+	Readiness(ctx context.Context) model.HealthReport
+	ListHotels(ctx context.Context, page, size int, filter model.HotelFilter) (model.HotelsPage, error)
+	GetLoyalty(ctx context.Context, username string) (model.Loyalty, error)
+	ListUserReservations(ctx context.Context, username string, filter model.ReservationFilter) ([]model.ReservationShort, int, error)
 	GetReservation(ctx context.Context, username, reservationUID string) (model.ReservationShort, error)
-	CreateReservation(ctx context.Context, username, hotelUID, startDateStr, endDateStr string) (model.ReservationCreateResponse, error)
+	CreateReservation(ctx context.Context, username, hotelUID, startDateStr, endDateStr, idempotencyKey string) (model.ReservationCreateResponse, error)
 	CancelReservation(ctx context.Context, username, reservationUID string) error
 	Me(ctx context.Context, username string) (model.MeResponse, error)
+	ListDeadLetters(ctx context.Context) ([]model.SagaTask, error)
+	ReplayDeadLetter(ctx context.Context, id int64) error
+	GetReservationRequest(ctx context.Context, username, requestUID string) (model.ReservationRequest, error)
+	Breakers(ctx context.Context) []circuitbreaker.Snapshot
+	Bulkheads(ctx context.Context) []bulkhead.Snapshot
+	Coalescing(ctx context.Context) []coalesce.Stats
 }
 
 type GatewayService struct {
 	reservationClient *clients.ReservationClient
 	paymentClient     *clients.PaymentClient
 	loyaltyClient     *clients.LoyaltyClient
+	breakers          *circuitbreaker.Registry
+	prober            *health.Prober
+
+	tasks       *repository.TaskRepository
+	requests    *repository.RequestRepository
+	idempotency *repository.IdempotencyRepository
+	retryPolicy retry.Policy
+
+	hotelPages *cache.Cache[model.HotelsPage]
+	hotels     *cache.Cache[model.Hotel]
+	loyalties  *cache.Cache[model.Loyalty]
+
+	hotelPageCalls  *coalesce.Group[model.HotelsPage]
+	hotelCalls      *coalesce.Group[model.Hotel]
+	hotelBatchCalls *coalesce.Group[map[string]model.Hotel]
+	loyaltyCalls    *coalesce.Group[model.Loyalty]
 
-	tasks chan func(context.Context)
+	enrichLimit int
 }
 
 func NewGatewayService(
 	resClient *clients.ReservationClient,
 	payClient *clients.PaymentClient,
 	loyalClient *clients.LoyaltyClient,
+	breakers *circuitbreaker.Registry,
+	prober *health.Prober,
+	tasks *repository.TaskRepository,
+	requests *repository.RequestRepository,
+	idempotency *repository.IdempotencyRepository,
+	retryPolicy retry.Policy,
+	cachePolicy cache.Policy,
+	enrichLimit int,
 ) *GatewayService {
 	s := &GatewayService{
 		reservationClient: resClient,
 		paymentClient:     payClient,
 		loyaltyClient:     loyalClient,
-		tasks:             make(chan func(context.Context), 100),
+		breakers:          breakers,
+		prober:            prober,
+		tasks:             tasks,
+		requests:          requests,
+		idempotency:       idempotency,
+		retryPolicy:       retryPolicy,
+		hotelPages:        cache.New[model.HotelsPage](cachePolicy),
+		hotels:            cache.New[model.Hotel](cachePolicy),
+		loyalties:         cache.New[model.Loyalty](cachePolicy),
+		hotelPageCalls:    coalesce.New[model.HotelsPage]("listHotels"),
+		hotelCalls:        coalesce.New[model.Hotel]("getHotel"),
+		hotelBatchCalls:   coalesce.New[map[string]model.Hotel]("getHotels"),
+		loyaltyCalls:      coalesce.New[model.Loyalty]("getLoyalty"),
+		enrichLimit:       enrichLimit,
+	}
+
+	if tasks != nil {
+		go s.runSagaWorker()
 	}
 
-	go s.runSagaWorker()
-
 	return s
 }
 
-func (s *GatewayService) runSagaWorker() {
-	for task := range s.tasks {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		task(ctx)
-		cancel()
+func (s *GatewayService) Breakers(ctx context.Context) []circuitbreaker.Snapshot {
+	if s.breakers == nil {
+		return []circuitbreaker.Snapshot{}
 	}
+	return s.breakers.Snapshots()
 }
 
-func (s *GatewayService) Health(ctx context.Context) error {
-	return nil
+func (s *GatewayService) Bulkheads(ctx context.Context) []bulkhead.Snapshot {
+	return []bulkhead.Snapshot{
+		s.reservationClient.Bulkhead().Snapshot(),
+		s.paymentClient.Bulkhead().Snapshot(),
+		s.loyaltyClient.Bulkhead().Snapshot(),
+	}
 }
 
-func (s *GatewayService) ListHotels(ctx context.Context, page, size int) (model.HotelsPage, error) {
-	return s.reservationClient.ListHotels(page, size)
+func (s *GatewayService) Coalescing(ctx context.Context) []coalesce.Stats {
+	return []coalesce.Stats{
+		s.hotelPageCalls.Stats(),
+		s.hotelCalls.Stats(),
+		s.hotelBatchCalls.Stats(),
+		s.loyaltyCalls.Stats(),
+	}
 }
 
-func (s *GatewayService) GetLoyalty(username string) (model.Loyalty, error) {
-	return s.loyaltyClient.GetLoyalty(username)
+func (s *GatewayService) ListHotels(ctx context.Context, page, size int, filter model.HotelFilter) (model.HotelsPage, error) {
+	key := fmt.Sprintf("%d:%d:%+v", page, size, filter)
+	resp, err := cachedRead(ctx, s.hotelPages, key, func(ctx context.Context) (model.HotelsPage, error) {
+		return s.hotelPageCalls.Do(ctx, key, func(ctx context.Context) (model.HotelsPage, error) {
+			return s.reservationClient.ListHotels(ctx, page, size, filter)
+		})
+	})
+	if isBadRequest(err) {
+		return model.HotelsPage{}, ErrInvalidFilter
+	}
+	return resp, err
 }
 
-func (s *GatewayService) ListUserReservations(ctx context.Context, username string) ([]model.ReservationShort, error) {
-	reservations, err := s.reservationClient.GetReservationsByUser(username)
-	if err != nil {
-		return nil, err
-	}
+func (s *GatewayService) GetLoyalty(ctx context.Context, username string) (model.Loyalty, error) {
+	return cachedRead(ctx, s.loyalties, username, func(ctx context.Context) (model.Loyalty, error) {
+		return s.fetchLoyalty(ctx, username)
+	})
+}
 
-	var result []model.ReservationShort
-	for _, r := range reservations {
-		h, err := s.reservationClient.GetHotel(r.HotelUID)
-		if err != nil {
-			return nil, err
-		}
-		p, err := s.paymentClient.GetPayment(r.PaymentUID)
-		if err != nil {
-			return nil, err
-		}
+// fetchHotel and fetchLoyalty share the downstream call with concurrent
+// lookups of the same key. The shared result is as fresh as a call of
+// one's own, so bookings use them too.
+func (s *GatewayService) fetchHotel(ctx context.Context, hotelUID string) (model.Hotel, error) {
+	return s.hotelCalls.Do(ctx, hotelUID, func(ctx context.Context) (model.Hotel, error) {
+		return s.reservationClient.GetHotel(ctx, hotelUID)
+	})
+}
 
-		h.FullAddress = fmt.Sprintf("%s, %s, %s", h.Country, h.City, h.Address)
+func (s *GatewayService) fetchLoyalty(ctx context.Context, username string) (model.Loyalty, error) {
+	return s.loyaltyCalls.Do(ctx, username, func(ctx context.Context) (model.Loyalty, error) {
+		return s.loyaltyClient.GetLoyalty(ctx, username)
+	})
+}
 
-		result = append(result, model.ReservationShort{
-			ReservationUID: r.ReservationUID,
-			Hotel:          h,
-			StartDate:      r.StartDate.Format("2006-01-02"),
-			EndDate:        r.EndDate.Format("2006-01-02"),
-			Status:         r.Status,
-			Payment:        p,
-		})
+// isBadRequest reports whether a downstream turned a call down as malformed,
+// which for list queries means the filter was invalid.
+func isBadRequest(err error) bool {
+	var se *clients.StatusError
+	return errors.As(err, &se) && se.Code == http.StatusBadRequest
+}
+
+// ListUserReservations returns the page of a user's reservations selected by
+// filter and the number of matches in total.
+func (s *GatewayService) ListUserReservations(ctx context.Context, username string, filter model.ReservationFilter) ([]model.ReservationShort, int, error) {
+	reservations, total, err := s.reservationClient.GetReservationsByUser(ctx, username, filter)
+	if isBadRequest(err) {
+		return nil, 0, ErrInvalidFilter
+	}
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return result, nil
+	if len(reservations) == 0 {
+		return nil, total, nil
+	}
+	result, err := s.enrich(ctx, reservations)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
 }
 
 func (s *GatewayService) GetReservation(ctx context.Context, username, reservationUID string) (model.ReservationShort, error) {
-	r, err := s.reservationClient.GetReservation(reservationUID)
+	r, err := s.reservationClient.GetReservation(ctx, reservationUID)
 	if err != nil {
 		return model.ReservationShort{}, err
 	}
@@ -110,29 +201,18 @@ func (s *GatewayService) GetReservation(ctx context.Context, username, reservati
 		return model.ReservationShort{}, errors.New("forbidden")
 	}
 
-	h, err := s.reservationClient.GetHotel(r.HotelUID)
+	result, err := s.enrich(ctx, []model.ReservationFull{r})
 	if err != nil {
 		return model.ReservationShort{}, err
 	}
-	p, err := s.paymentClient.GetPayment(r.PaymentUID)
-	if err != nil {
-		return model.ReservationShort{}, err
-	}
-
-	h.FullAddress = fmt.Sprintf("%s, %s, %s", h.Country, h.City, h.Address)
-
-	return model.ReservationShort{
-		ReservationUID: r.ReservationUID,
-		Hotel:          h,
-		StartDate:      r.StartDate.Format("2006-01-02"),
-		EndDate:        r.EndDate.Format("2006-01-02"),
-		Status:         r.Status,
-		Payment:        p,
-	}, nil
+	return result[0], nil
 }
 
-func (s *GatewayService) createReservationOnce(ctx context.Context, username, hotelUID, startDateStr, endDateStr string) (model.ReservationCreateResponse, error) {
-	hotel, err := s.reservationClient.GetHotel(hotelUID)
+// createReservationOnce runs the booking saga. When idem is set, the steps it
+// already finished are reused instead of being executed again, and progress is
+// recorded so that a later replay can resume from the same point.
+func (s *GatewayService) createReservationOnce(ctx context.Context, username, hotelUID, startDateStr, endDateStr string, idem *model.IdempotencyRecord) (model.ReservationCreateResponse, error) {
+	hotel, err := s.fetchHotel(ctx, hotelUID)
 	if err != nil {
 		return model.ReservationCreateResponse{}, err
 	}
@@ -149,7 +229,7 @@ func (s *GatewayService) createReservationOnce(ctx context.Context, username, ho
 		return model.ReservationCreateResponse{}, err
 	}
 
-	loyalty, err := s.GetLoyalty(username)
+	loyalty, err := s.fetchLoyalty(ctx, username)
 	if err != nil {
 		return model.ReservationCreateResponse{}, ErrServiceUnavailable
 	}
@@ -162,42 +242,75 @@ func (s *GatewayService) createReservationOnce(ctx context.Context, username, ho
 	finalPrice := basePrice - (basePrice * loyalty.Discount / 100)
 
 	var payment model.Payment
-	paymentCreated := false
-
-	payment, err = s.paymentClient.CreatePayment(username, finalPrice)
-	if err != nil {
-		return model.ReservationCreateResponse{}, err
-	}
-	paymentCreated = true
+	if idem != nil && idem.PaymentUID != "" {
+		payment, err = s.paymentClient.GetPayment(ctx, idem.PaymentUID)
+		if err != nil {
+			return model.ReservationCreateResponse{}, err
+		}
+		finalPrice = payment.Price
+	} else {
+		// The operation key is stored before the call, so a replay after a
+		// lost response gets the same payment back instead of a second one.
+		paymentKey := uuid.New().String()
+		if idem != nil && idem.PaymentKey != "" {
+			paymentKey = idem.PaymentKey
+		}
+		if err := s.recordPayment(ctx, idem, paymentKey, ""); err != nil {
+			return model.ReservationCreateResponse{}, err
+		}
 
-	internalReq := model.ReservationInternal{
-		Username:   username,
-		HotelUID:   hotel.HotelUID,
-		StartDate:  start,
-		EndDate:    end,
-		Status:     "PAID",
-		PaymentUID: payment.PaymentUID,
+		payment, err = s.paymentClient.CreatePayment(ctx, username, finalPrice, paymentKey)
+		if err != nil {
+			return model.ReservationCreateResponse{}, err
+		}
+		if err := s.recordPayment(ctx, idem, paymentKey, payment.PaymentUID); err != nil {
+			if err := s.paymentClient.CancelPayment(context.WithoutCancel(ctx), payment.PaymentUID); err != nil {
+				s.enqueue(model.TaskPaymentCancel, model.PaymentTaskPayload{PaymentUID: payment.PaymentUID})
+			}
+			return model.ReservationCreateResponse{}, err
+		}
 	}
 
-	fullRes, err := s.reservationClient.CreateReservation(internalReq)
-	if err != nil {
-		if paymentCreated {
-			_ = s.paymentClient.CancelPayment(payment.PaymentUID)
+	var fullRes model.ReservationFull
+	if idem != nil && idem.ReservationUID != "" {
+		fullRes, err = s.reservationClient.GetReservation(ctx, idem.ReservationUID)
+		if err != nil {
+			return model.ReservationCreateResponse{}, err
+		}
+	} else {
+		internalReq := model.ReservationInternal{
+			Username:   username,
+			HotelUID:   hotel.HotelUID,
+			StartDate:  start,
+			EndDate:    end,
+			Status:     "PAID",
+			PaymentUID: payment.PaymentUID,
 		}
-		return model.ReservationCreateResponse{}, err
-	}
 
-	if err := s.loyaltyClient.IncrementReservation(username); err != nil {
-		if s.tasks != nil {
-			select {
-			case s.tasks <- func(ctx context.Context) {
-				_ = s.loyaltyClient.IncrementReservation(username)
-			}:
-			default:
+		fullRes, err = s.reservationClient.CreateReservation(ctx, internalReq)
+		if err != nil {
+			// The compensation must finish even if the caller has gone away.
+			cctx := context.WithoutCancel(ctx)
+			if err := s.paymentClient.CancelPayment(cctx, payment.PaymentUID); err != nil {
+				s.enqueue(model.TaskPaymentCancel, model.PaymentTaskPayload{PaymentUID: payment.PaymentUID})
+			}
+			if err := s.recordPayment(cctx, idem, "", ""); err != nil {
+				log.Printf("saga: %v", err)
 			}
+			return model.ReservationCreateResponse{}, err
+		}
+		if err := s.recordReservation(ctx, idem, fullRes.ReservationUID); err != nil {
+			log.Printf("saga: %v", err)
 		}
 	}
 
+	// Increments are keyed by reservation, so a resumed saga may repeat one
+	// that already went through.
+	loyaltyTask := model.LoyaltyTaskPayload{Username: username, ReservationUID: fullRes.ReservationUID}
+	if err := s.loyaltyClient.IncrementReservation(ctx, username, fullRes.ReservationUID); err != nil {
+		s.enqueue(model.TaskLoyaltyIncrement, loyaltyTask)
+	}
+
 	resp := model.ReservationCreateResponse{
 		ReservationUID: fullRes.ReservationUID,
 		HotelUID:       hotel.HotelUID,
@@ -211,106 +324,192 @@ func (s *GatewayService) createReservationOnce(ctx context.Context, username, ho
 		},
 	}
 
+	if err := s.recordResponse(ctx, idem, resp); err != nil {
+		log.Printf("saga: %v", err)
+	}
+
 	return resp, nil
 }
 
-func (s *GatewayService) CreateReservation(ctx context.Context, username, hotelUID, startDateStr, endDateStr string) (model.ReservationCreateResponse, error) {
-	resp, err := s.createReservationOnce(ctx, username, hotelUID, startDateStr, endDateStr)
+func (s *GatewayService) CreateReservation(ctx context.Context, username, hotelUID, startDateStr, endDateStr, idempotencyKey string) (model.ReservationCreateResponse, error) {
+	if err := validateReservation(hotelUID, startDateStr, endDateStr); err != nil {
+		return model.ReservationCreateResponse{}, err
+	}
+	hash := requestHash(hotelUID, startDateStr, endDateStr)
+
+	var idem *model.IdempotencyRecord
+	if idempotencyKey != "" {
+		rec, stored, err := s.acquireIdempotencyKey(ctx, username, idempotencyKey, hash)
+		if err != nil {
+			return model.ReservationCreateResponse{}, err
+		}
+		if stored != nil {
+			return *stored, nil
+		}
+		idem = rec
+	}
+
+	resp, err := s.createReservationOnce(ctx, username, hotelUID, startDateStr, endDateStr, idem)
 	if err == nil {
 		return resp, nil
 	}
 
-	if errors.Is(err, ErrHotelNotFound) {
+	if errors.Is(err, ErrHotelNotFound) || errors.Is(err, ErrServiceUnavailable) {
+		s.releaseIdempotencyKey(ctx, idem)
 		return model.ReservationCreateResponse{}, err
 	}
 
-	if errors.Is(err, ErrServiceUnavailable) {
-		return model.ReservationCreateResponse{}, ErrServiceUnavailable
-	}
+	requestUID := s.trackReservationRequest(ctx, username, hotelUID, startDateStr, endDateStr)
 
-	if s.tasks != nil {
-		select {
-		case s.tasks <- func(ctx context.Context) {
-			_, _ = s.createReservationOnce(ctx, username, hotelUID, startDateStr, endDateStr)
-		}:
-		default:
+	// A queued replay always runs under a key, so it cannot repeat the
+	// steps it has already completed.
+	if idem == nil && requestUID != "" {
+		idempotencyKey = requestUID
+		if rec, _, err := s.acquireIdempotencyKey(ctx, username, idempotencyKey, hash); err == nil {
+			idem = rec
+		} else {
+			log.Printf("saga: %v", err)
+			idempotencyKey = ""
 		}
 	}
 
-	return model.ReservationCreateResponse{
-		ReservationUID: "",
+	task := model.ReservationTaskPayload{
+		RequestUID:     requestUID,
+		IdempotencyKey: idempotencyKey,
+		Username:       username,
 		HotelUID:       hotelUID,
 		StartDate:      startDateStr,
 		EndDate:        endDateStr,
+	}
+	s.enqueue(model.TaskReservationCreate, task)
+
+	// The queued saga replaces this response with its outcome, or clears it
+	// if it ends up dead-lettered.
+	resp = pendingResponse(task)
+	if err := s.recordResponse(ctx, idem, resp); err != nil {
+		log.Printf("saga: %v", err)
+	}
+
+	return resp, nil
+}
+
+// validateReservation rejects a request that no retry could complete, before
+// it reaches the downstreams or the queue.
+func validateReservation(hotelUID, startDateStr, endDateStr string) error {
+	if _, err := uuid.Parse(hotelUID); err != nil {
+		return fmt.Errorf("%w: hotelUid must be a UUID", ErrInvalidReservation)
+	}
+	for _, date := range []string{startDateStr, endDateStr} {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("%w: dates must be given as YYYY-MM-DD", ErrInvalidReservation)
+		}
+	}
+	return nil
+}
+
+func pendingResponse(p model.ReservationTaskPayload) model.ReservationCreateResponse {
+	return model.ReservationCreateResponse{
+		ReservationUID: "",
+		RequestUID:     p.RequestUID,
+		HotelUID:       p.HotelUID,
+		StartDate:      p.StartDate,
+		EndDate:        p.EndDate,
 		Discount:       0,
 		Status:         "PENDING",
 		Payment: model.PaymentCreateResponse{
 			Status: "PENDING",
 			Price:  0,
 		},
-	}, nil
+	}
+}
+
+// trackReservationRequest records a queued reservation so the client can poll
+// its outcome. It returns an empty id when tracking is unavailable.
+func (s *GatewayService) trackReservationRequest(ctx context.Context, username, hotelUID, startDateStr, endDateStr string) string {
+	if s.requests == nil {
+		return ""
+	}
+
+	req := model.ReservationRequest{
+		RequestUID: uuid.New().String(),
+		Username:   username,
+		HotelUID:   hotelUID,
+		StartDate:  startDateStr,
+		EndDate:    endDateStr,
+		Status:     model.RequestPending,
+	}
+	if err := s.requests.Create(ctx, req); err != nil {
+		log.Printf("saga: track reservation request: %v", err)
+		return ""
+	}
+	return req.RequestUID
+}
+
+func (s *GatewayService) GetReservationRequest(ctx context.Context, username, requestUID string) (model.ReservationRequest, error) {
+	if s.requests == nil {
+		return model.ReservationRequest{}, nil
+	}
+	if _, err := uuid.Parse(requestUID); err != nil {
+		return model.ReservationRequest{}, nil
+	}
+
+	req, err := s.requests.Get(ctx, requestUID)
+	if err != nil {
+		return model.ReservationRequest{}, err
+	}
+	if req.RequestUID == "" {
+		return model.ReservationRequest{}, nil
+	}
+	if req.Username != username {
+		return model.ReservationRequest{}, ErrForbidden
+	}
+	return req, nil
 }
 
 func (s *GatewayService) CancelReservation(ctx context.Context, username, reservationUID string) error {
-	r, err := s.reservationClient.GetReservation(reservationUID)
+	r, err := s.reservationClient.GetReservation(ctx, reservationUID)
 	if err != nil {
 		return err
 	}
 	if r.ReservationUID == "" {
 		return nil
 	}
-	fmt.Println("CANCEL CHECK:", r.Username, "vs", username)
 	if r.Username != username {
 		return errors.New("forbidden")
 	}
 
-	if err := s.reservationClient.CancelReservation(reservationUID); err != nil {
-		fmt.Println("CANCEL_RESERVATION ERROR", err)
+	if err := s.reservationClient.CancelReservation(ctx, reservationUID); err != nil {
+		log.Printf("saga: cancel reservation %s: %v", reservationUID, err)
+		if errors.Is(err, clients.ErrCircuitOpen) {
+			return ErrServiceUnavailable
+		}
 		return err
 	}
-	if err := s.paymentClient.CancelPayment(r.PaymentUID); err != nil {
-		fmt.Println("PAYMENT ERROR:", err)
-		if s.tasks != nil {
-			select {
-			case s.tasks <- func(ctx context.Context) {
-				_ = s.paymentClient.CancelPayment(r.PaymentUID)
-			}:
-			default:
-			}
-		}
+	if err := s.paymentClient.CancelPayment(ctx, r.PaymentUID); err != nil {
+		log.Printf("saga: cancel payment %s queued: %v", r.PaymentUID, err)
+		s.enqueue(model.TaskPaymentCancel, model.PaymentTaskPayload{PaymentUID: r.PaymentUID})
 		return nil
 	}
 
-	if err := s.loyaltyClient.DecrementReservation(username); err != nil {
-		if s.tasks != nil {
-			select {
-			case s.tasks <- func(ctx context.Context) {
-				for {
-					if err := s.loyaltyClient.DecrementReservation(username); err == nil {
-						return
-					}
-					select {
-					case <-ctx.Done():
-						return
-					case <-time.After(1 * time.Second):
-					}
-				}
-			}:
-			default:
-			}
-		}
+	if err := s.loyaltyClient.DecrementReservation(ctx, username, reservationUID); err != nil {
+		s.enqueue(model.TaskLoyaltyDecrement, model.LoyaltyTaskPayload{Username: username, ReservationUID: reservationUID})
 	}
 
 	return nil
 }
 
 func (s *GatewayService) Me(ctx context.Context, username string) (model.MeResponse, error) {
-	loyalty, err := s.GetLoyalty(username)
+	// The profile shows the loyalty as it is now or not at all, never a
+	// cached copy.
+	var degraded []string
+	loyalty, err := s.fetchLoyalty(ctx, username)
 	if err != nil {
+		log.Printf("me: loyalty degraded: %v", err)
 		loyalty = model.Loyalty{}
+		degraded = append(degraded, DegradedLoyalty)
 	}
 
-	reservations, err := s.ListUserReservations(ctx, username)
+	reservations, _, err := s.ListUserReservations(ctx, username, model.ReservationFilter{})
 	if err != nil {
 		return model.MeResponse{}, err
 	}
@@ -319,5 +518,6 @@ func (s *GatewayService) Me(ctx context.Context, username string) (model.MeRespo
 		Username:     username,
 		Loyalty:      loyalty,
 		Reservations: reservations,
+		Degraded:     degraded,
 	}, nil
 }
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCreateReservation_RejectsMalformedInputBeforeQueuing(t *testing.T) {
+	s := &GatewayService{}
+
+	cases := []struct{ hotelUID, start, end string }{
+		{"not-a-uuid", "2021-10-08", "2021-10-11"},
+		{"049161bb-badd-4fa8-9d90-87c9a82b0668", "08.10.2021", "2021-10-11"},
+		{"049161bb-badd-4fa8-9d90-87c9a82b0668", "2021-10-08", ""},
+	}
+	for _, c := range cases {
+		_, err := s.CreateReservation(context.Background(), "Test Max", c.hotelUID, c.start, c.end, "")
+		if !errors.Is(err, ErrInvalidReservation) {
+			t.Fatalf("%+v: expected ErrInvalidReservation, got %v", c, err)
+		}
+	}
+}
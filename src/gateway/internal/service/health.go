@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/health"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+// Health is the liveness check: the gateway is healthy whenever it answers,
+// whatever the state of its downstreams. Those are reported by Readiness.
+func (s *GatewayService) Health(ctx context.Context) error {
+	return nil
+}
+
+// Readiness reports every downstream as last seen by the prober, with the
+// state of its breakers. A downstream that answers its probe while one of
+// its breakers is not closed is degraded. The gateway is down when all of
+// them are down and degraded when any of them is not up.
+func (s *GatewayService) Readiness(ctx context.Context) model.HealthReport {
+	var results []health.Result
+	if s.prober != nil {
+		results = s.prober.Results()
+	} else {
+		for _, name := range []string{clients.ReservationService, clients.PaymentService, clients.LoyaltyService} {
+			results = append(results, health.Result{Name: name, Status: health.Unknown})
+		}
+	}
+
+	report := model.HealthReport{
+		Status:       health.Up,
+		Dependencies: make([]model.DependencyHealth, 0, len(results)),
+	}
+	down := 0
+	for _, r := range results {
+		dep := model.DependencyHealth{
+			Name:      r.Name,
+			Status:    r.Status,
+			LatencyMs: r.Latency.Milliseconds(),
+			Breakers:  []model.BreakerState{},
+		}
+		if !r.CheckedAt.IsZero() {
+			checkedAt := r.CheckedAt
+			dep.CheckedAt = &checkedAt
+		}
+		if r.Err != nil {
+			dep.Error = r.Err.Error()
+		}
+
+		if s.breakers != nil {
+			for _, cb := range s.breakers.Service(r.Name) {
+				snap := cb.Snapshot()
+				dep.Breakers = append(dep.Breakers, model.BreakerState{Name: snap.Name, State: snap.State.String()})
+				if snap.State != circuitbreaker.Closed && dep.Status == health.Up {
+					dep.Status = health.Degraded
+				}
+			}
+		}
+
+		if dep.Status == health.Down {
+			down++
+		}
+		if dep.Status != health.Up {
+			report.Status = health.Degraded
+		}
+		report.Dependencies = append(report.Dependencies, dep)
+	}
+	if down > 0 && down == len(results) {
+		report.Status = health.Down
+	}
+
+	return report
+}
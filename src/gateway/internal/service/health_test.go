@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/circuitbreaker"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/health"
+)
+
+func TestReadiness_OpenBreakerDegrades(t *testing.T) {
+	breakers := circuitbreaker.NewRegistry(circuitbreaker.Policy{WindowSize: 1, FailureThreshold: 0.5, OpenTimeout: time.Minute})
+	breakers.Get(clients.PaymentService, "getPayment").Record(false)
+
+	prober := health.NewProber(time.Minute, time.Second)
+	prober.Add(clients.ReservationService, func(context.Context) error { return nil })
+	prober.Add(clients.PaymentService, func(context.Context) error { return nil })
+	prober.ProbeAll(context.Background())
+
+	s := &GatewayService{breakers: breakers, prober: prober}
+	report := s.Readiness(context.Background())
+
+	if report.Status != health.Degraded {
+		t.Fatalf("expected degraded, got %+v", report)
+	}
+	if dep := report.Dependencies[0]; dep.Status != health.Up {
+		t.Fatalf("expected reservation-service to be up: %+v", dep)
+	}
+	if dep := report.Dependencies[1]; dep.Status != health.Degraded || len(dep.Breakers) != 1 || dep.Breakers[0].State != "OPEN" {
+		t.Fatalf("expected payment-service to be degraded by its breaker: %+v", dep)
+	}
+	if err := s.Health(context.Background()); err != nil {
+		t.Fatalf("expected a degraded gateway to be healthy, got %v", err)
+	}
+}
+
+func TestReadiness_AllDownIsDown(t *testing.T) {
+	prober := health.NewProber(time.Minute, time.Second)
+	prober.Add(clients.ReservationService, func(context.Context) error { return errors.New("refused") })
+	prober.Add(clients.LoyaltyService, func(context.Context) error { return errors.New("refused") })
+	prober.ProbeAll(context.Background())
+
+	s := &GatewayService{breakers: circuitbreaker.NewRegistry(circuitbreaker.DefaultPolicy()), prober: prober}
+
+	if report := s.Readiness(context.Background()); report.Status != health.Down {
+		t.Fatalf("expected down, got %+v", report)
+	}
+	if err := s.Health(context.Background()); err != nil {
+		t.Fatalf("expected liveness not to depend on downstreams, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+// idempotencyLockTTL is how long a key without a response stays owned by the
+// request that created it. After that a retry may resume the saga.
+const idempotencyLockTTL = 30 * time.Second
+
+func requestHash(hotelUID, startDateStr, endDateStr string) string {
+	sum := sha256.Sum256([]byte(hotelUID + "|" + startDateStr + "|" + endDateStr))
+	return hex.EncodeToString(sum[:])
+}
+
+// acquireIdempotencyKey returns either the record the saga should run under,
+// or the response already stored for the key.
+func (s *GatewayService) acquireIdempotencyKey(ctx context.Context, username, key, hash string) (*model.IdempotencyRecord, *model.ReservationCreateResponse, error) {
+	if s.idempotency == nil {
+		return nil, nil, nil
+	}
+
+	rec, created, err := s.idempotency.Acquire(ctx, username, key, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if created {
+		return &rec, nil, nil
+	}
+
+	if rec.RequestHash != hash {
+		return nil, nil, ErrIdempotencyKeyReused
+	}
+
+	if len(rec.Response) > 0 {
+		var stored model.ReservationCreateResponse
+		if err := json.Unmarshal(rec.Response, &stored); err != nil {
+			return nil, nil, fmt.Errorf("decode stored response: %w", err)
+		}
+		return nil, &stored, nil
+	}
+
+	ok, err := s.idempotency.TakeOver(ctx, rec.ID, time.Now().Add(-idempotencyLockTTL))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrIdempotencyKeyInProgress
+	}
+	return &rec, nil, nil
+}
+
+func (s *GatewayService) loadIdempotencyKey(ctx context.Context, username, key string) (*model.IdempotencyRecord, error) {
+	if key == "" || s.idempotency == nil {
+		return nil, nil
+	}
+
+	rec, err := s.idempotency.Get(ctx, username, key)
+	if err != nil {
+		return nil, err
+	}
+	if rec.ID == 0 {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (s *GatewayService) releaseIdempotencyKey(ctx context.Context, idem *model.IdempotencyRecord) {
+	if idem == nil {
+		return
+	}
+	if err := s.idempotency.Delete(ctx, idem.ID); err != nil {
+		log.Printf("saga: %v", err)
+	}
+}
+
+func (s *GatewayService) recordPayment(ctx context.Context, idem *model.IdempotencyRecord, paymentKey, paymentUID string) error {
+	if idem == nil {
+		return nil
+	}
+	idem.PaymentKey = paymentKey
+	idem.PaymentUID = paymentUID
+	return s.idempotency.SetPayment(ctx, idem.ID, paymentKey, paymentUID)
+}
+
+func (s *GatewayService) recordReservation(ctx context.Context, idem *model.IdempotencyRecord, reservationUID string) error {
+	if idem == nil {
+		return nil
+	}
+	idem.ReservationUID = reservationUID
+	return s.idempotency.SetReservation(ctx, idem.ID, reservationUID)
+}
+
+func (s *GatewayService) recordResponse(ctx context.Context, idem *model.IdempotencyRecord, resp model.ReservationCreateResponse) error {
+	if idem == nil {
+		return nil
+	}
+	return s.idempotency.SaveResponse(ctx, idem.ID, resp)
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/model"
+)
+
+const (
+	sagaPollInterval = 1 * time.Second
+	sagaTaskTimeout  = 10 * time.Second
+
+	// sagaMaxPostponements bounds how often a task may be turned away by an
+	// open breaker or a full bulkhead before it is dead-lettered. With the
+	// backoff growing up to the policy's MaxDelay, that is between half an
+	// hour and an hour under the default settings.
+	sagaMaxPostponements = 20
+)
+
+func (s *GatewayService) enqueue(kind string, payload interface{}) {
+	if s.tasks == nil {
+		return
+	}
+	if err := s.tasks.Enqueue(context.Background(), kind, payload); err != nil {
+		log.Printf("saga: enqueue %s: %v", kind, err)
+	}
+}
+
+func (s *GatewayService) runSagaWorker() {
+	ctx := context.Background()
+
+	if n, err := s.tasks.RequeueRunning(ctx); err != nil {
+		log.Printf("saga: %v", err)
+	} else if n > 0 {
+		log.Printf("saga: requeued %d interrupted tasks", n)
+	}
+
+	for {
+		task, ok, err := s.tasks.Claim(ctx)
+		if err != nil {
+			log.Printf("saga: %v", err)
+		}
+		if !ok {
+			time.Sleep(sagaPollInterval)
+			continue
+		}
+
+		taskCtx, cancel := context.WithTimeout(ctx, sagaTaskTimeout)
+		err = s.runTask(taskCtx, task)
+		cancel()
+
+		if err == nil {
+			if err := s.tasks.MarkDone(ctx, task.ID); err != nil {
+				log.Printf("saga: %v", err)
+			}
+			continue
+		}
+		s.handleTaskFailure(ctx, task, err)
+	}
+}
+
+func (s *GatewayService) handleTaskFailure(ctx context.Context, task model.SagaTask, taskErr error) {
+	// An open breaker or a full bulkhead rejected the call before it reached
+	// the downstream, so the attempt is given back and the task waits, longer
+	// every time, until the downstream has been away for too long.
+	if errors.Is(taskErr, clients.ErrCircuitOpen) || errors.Is(taskErr, ErrOverloaded) {
+		if task.Postponements+1 >= sagaMaxPostponements {
+			log.Printf("saga: task %d (%s) dead after %d postponements: %v", task.ID, task.Kind, task.Postponements+1, taskErr)
+			s.deadLetter(ctx, task, taskErr)
+			return
+		}
+		delay := s.retryPolicy.Backoff(task.Postponements + 1)
+		log.Printf("saga: task %d (%s) postponed for %v: %v", task.ID, task.Kind, delay, taskErr)
+		if err := s.tasks.Postpone(ctx, task.ID, taskErr.Error(), time.Now().Add(delay)); err != nil {
+			log.Printf("saga: %v", err)
+		}
+		return
+	}
+
+	if !isRetryable(taskErr) || s.retryPolicy.Exhausted(task.Attempts) {
+		log.Printf("saga: task %d (%s) dead after %d attempts: %v", task.ID, task.Kind, task.Attempts, taskErr)
+		s.deadLetter(ctx, task, taskErr)
+		return
+	}
+
+	delay := s.retryPolicy.Backoff(task.Attempts)
+	log.Printf("saga: task %d (%s) attempt %d failed, retry in %v: %v", task.ID, task.Kind, task.Attempts, delay, taskErr)
+	if err := s.tasks.Reschedule(ctx, task.ID, taskErr.Error(), time.Now().Add(delay)); err != nil {
+		log.Printf("saga: %v", err)
+	}
+}
+
+// deadLetter moves the task to the dead-letter list and fails the request it
+// was tracked under, if any.
+func (s *GatewayService) deadLetter(ctx context.Context, task model.SagaTask, taskErr error) {
+	if err := s.tasks.MarkDead(ctx, task.ID, taskErr.Error()); err != nil {
+		log.Printf("saga: %v", err)
+	}
+	if p, ok := reservationTask(task); ok {
+		if p.RequestUID != "" && s.requests != nil {
+			if err := s.requests.Fail(ctx, p.RequestUID, taskErr.Error()); err != nil {
+				log.Printf("saga: %v", err)
+			}
+		}
+		s.releaseQueuedKey(ctx, p)
+	}
+}
+
+// isRetryable extends the client classification with the gateway's own
+// transient errors.
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrServiceUnavailable) || clients.IsRetryable(err)
+}
+
+func (s *GatewayService) ListDeadLetters(ctx context.Context) ([]model.SagaTask, error) {
+	if s.tasks == nil {
+		return []model.SagaTask{}, nil
+	}
+	return s.tasks.ListDead(ctx)
+}
+
+func (s *GatewayService) ReplayDeadLetter(ctx context.Context, id int64) error {
+	if s.tasks == nil {
+		return ErrTaskNotFound
+	}
+	task, ok, err := s.tasks.Replay(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTaskNotFound
+	}
+	p, ok := reservationTask(task)
+	if !ok {
+		return nil
+	}
+	if err := s.reholdQueuedKey(ctx, p); err != nil {
+		return err
+	}
+	if p.RequestUID != "" && s.requests != nil {
+		return s.requests.Reopen(ctx, p.RequestUID)
+	}
+	return nil
+}
+
+// reservationTask decodes the payload of a queued reservation replay. It
+// reports false for other task kinds.
+func reservationTask(task model.SagaTask) (model.ReservationTaskPayload, bool) {
+	var p model.ReservationTaskPayload
+	if task.Kind != model.TaskReservationCreate {
+		return p, false
+	}
+	if err := json.Unmarshal(task.Payload, &p); err != nil {
+		return p, false
+	}
+	return p, true
+}
+
+// releaseQueuedKey clears the PENDING response stored under the key of a
+// dead-lettered replay, so that a retry with the key resumes the saga from
+// the steps already recorded instead of replaying PENDING forever.
+func (s *GatewayService) releaseQueuedKey(ctx context.Context, p model.ReservationTaskPayload) {
+	idem, err := s.loadIdempotencyKey(ctx, p.Username, p.IdempotencyKey)
+	if err != nil {
+		log.Printf("saga: %v", err)
+		return
+	}
+	if idem == nil {
+		return
+	}
+	if err := s.idempotency.ClearResponse(ctx, idem.ID); err != nil {
+		log.Printf("saga: %v", err)
+	}
+}
+
+// reholdQueuedKey stores the PENDING response again when a dead-lettered
+// replay is put back in the queue, unless a retry has answered the key in
+// the meantime.
+func (s *GatewayService) reholdQueuedKey(ctx context.Context, p model.ReservationTaskPayload) error {
+	idem, err := s.loadIdempotencyKey(ctx, p.Username, p.IdempotencyKey)
+	if err != nil {
+		return err
+	}
+	if idem == nil || len(idem.Response) > 0 {
+		return nil
+	}
+	return s.recordResponse(ctx, idem, pendingResponse(p))
+}
+
+func (s *GatewayService) runTask(ctx context.Context, task model.SagaTask) error {
+	switch task.Kind {
+	case model.TaskLoyaltyIncrement:
+		var p model.LoyaltyTaskPayload
+		if err := json.Unmarshal(task.Payload, &p); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		return s.loyaltyClient.IncrementReservation(ctx, p.Username, p.ReservationUID)
+
+	case model.TaskLoyaltyDecrement:
+		var p model.LoyaltyTaskPayload
+		if err := json.Unmarshal(task.Payload, &p); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		return s.loyaltyClient.DecrementReservation(ctx, p.Username, p.ReservationUID)
+
+	case model.TaskPaymentCancel:
+		var p model.PaymentTaskPayload
+		if err := json.Unmarshal(task.Payload, &p); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		return s.paymentClient.CancelPayment(ctx, p.PaymentUID)
+
+	case model.TaskReservationCreate:
+		var p model.ReservationTaskPayload
+		if err := json.Unmarshal(task.Payload, &p); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		idem, err := s.loadIdempotencyKey(ctx, p.Username, p.IdempotencyKey)
+		if err != nil {
+			return err
+		}
+		resp, err := s.createReservationOnce(ctx, p.Username, p.HotelUID, p.StartDate, p.EndDate, idem)
+		if err != nil {
+			return err
+		}
+		if p.RequestUID != "" && s.requests != nil {
+			return s.requests.Complete(ctx, p.RequestUID, resp.ReservationUID)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown task kind %q", task.Kind)
+	}
+}
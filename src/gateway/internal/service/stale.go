@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/cache"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
+)
+
+type stalenessKey struct{}
+
+// Staleness reports whether a response was built from cached data because a
+// downstream was unavailable, and the age of the oldest cached part.
+type Staleness struct {
+	mu     sync.Mutex
+	stale  bool
+	oldest time.Time
+}
+
+// WithStaleness returns a context that collects staleness for one request.
+func WithStaleness(ctx context.Context) (context.Context, *Staleness) {
+	st := &Staleness{}
+	return context.WithValue(ctx, stalenessKey{}, st), st
+}
+
+// Stale reports whether any part of the response was served from the cache
+// and, if so, since when the oldest part has not been refreshed.
+func (st *Staleness) Stale() (bool, time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.stale, st.oldest
+}
+
+func markStale(ctx context.Context, storedAt time.Time) {
+	st, ok := ctx.Value(stalenessKey{}).(*Staleness)
+	if !ok {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if !st.stale || storedAt.Before(st.oldest) {
+		st.oldest = storedAt
+	}
+	st.stale = true
+}
+
+// cachedRead calls fetch and remembers the result. While the breaker of the
+// downstream is open, the last remembered result is served instead, the
+// response is marked stale and a refresh is started in the background. Other
+// failures, including the ones that open the breaker, are passed through.
+// The refresh outlives the request, so it gets a context of its own.
+func cachedRead[V any](ctx context.Context, c *cache.Cache[V], key string, fetch func(context.Context) (V, error)) (V, error) {
+	v, err := fetch(ctx)
+	if err == nil {
+		c.Set(key, v)
+		return v, nil
+	}
+	if !errors.Is(err, clients.ErrCircuitOpen) {
+		return v, err
+	}
+
+	cached, storedAt, ok := c.Get(key)
+	if !ok {
+		return v, err
+	}
+
+	markStale(ctx, storedAt)
+	c.Revalidate(key, func() (V, error) {
+		return fetch(context.Background())
+	})
+	return cached, nil
+}
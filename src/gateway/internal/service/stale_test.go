@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/cache"
+	"github.com/gazizov-ai/lab2-rsoi/src/gateway/internal/clients"
+)
+
+func TestCachedRead_ServesStaleWhenCircuitOpen(t *testing.T) {
+	c := cache.New[string](cache.Policy{Capacity: 4, MaxStale: time.Hour, RefreshInterval: time.Hour})
+
+	ctx, st := WithStaleness(context.Background())
+	if _, err := cachedRead(ctx, c, "k", func(context.Context) (string, error) { return "fresh", nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stale, _ := st.Stale(); stale {
+		t.Fatalf("expected a fresh response not to be marked stale")
+	}
+
+	ctx, st = WithStaleness(context.Background())
+	v, err := cachedRead(ctx, c, "k", func(context.Context) (string, error) { return "", clients.ErrCircuitOpen })
+	if err != nil || v != "fresh" {
+		t.Fatalf("expected cached value, got %q, %v", v, err)
+	}
+	if stale, _ := st.Stale(); !stale {
+		t.Fatalf("expected the response to be marked stale")
+	}
+}
+
+func TestCachedRead_DoesNotHideFinalErrors(t *testing.T) {
+	c := cache.New[string](cache.Policy{Capacity: 4, MaxStale: time.Hour, RefreshInterval: time.Hour})
+	c.Set("k", "cached")
+
+	boom := errors.New("decode failed")
+	if _, err := cachedRead(context.Background(), c, "k", func(context.Context) (string, error) { return "", boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected the error to pass through, got %v", err)
+	}
+}
+
+func TestCachedRead_PassesThroughWhileCircuitClosed(t *testing.T) {
+	c := cache.New[string](cache.Policy{Capacity: 4, MaxStale: time.Hour, RefreshInterval: time.Hour})
+	c.Set("k", "cached")
+
+	refused := &clients.StatusError{Op: "get loyalty", Code: http.StatusServiceUnavailable}
+	ctx, st := WithStaleness(context.Background())
+	if _, err := cachedRead(ctx, c, "k", func(context.Context) (string, error) { return "", refused }); !errors.Is(err, refused) {
+		t.Fatalf("expected the error to pass through, got %v", err)
+	}
+	if stale, _ := st.Stale(); stale {
+		t.Fatalf("expected no cached data to be served")
+	}
+}
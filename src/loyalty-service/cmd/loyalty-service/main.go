@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
@@ -14,7 +15,11 @@ import (
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+
 	db, err := sql.Open("postgres", cfg.DBDSN)
 	if err != nil {
 		log.Fatalf("failed to open db: %v", err)
@@ -25,8 +30,14 @@ func main() {
 		log.Fatalf("failed to ping db: %v", err)
 	}
 
-	repo := repository.NewLoyaltyRepository(db)
+	repo := repository.NewLoyaltyRepository(db, cfg.Tiers)
 	svc := service.NewLoyaltyService(repo)
+
+	if n, err := svc.Reconcile(context.Background()); err != nil {
+		log.Printf("reconcile loyalties: %v", err)
+	} else if n > 0 {
+		log.Printf("reconciled %d loyalty counters with their events", n)
+	}
 	router := httpserver.NewRouter(svc)
 
 	log.Printf("loyalty-service listening on %s", cfg.Addr())
@@ -76,11 +76,17 @@ func (h *Handler) Loyalty(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(resp)
 
 	case http.MethodPost:
+		reservationUID, ok := decodeReservationUID(r)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
 		var err error
 		if isDecrement {
-			err = h.loyaltyService.DecrementReservationCount(r.Context(), username)
+			err = h.loyaltyService.DecrementReservationCount(r.Context(), username, reservationUID)
 		} else {
-			err = h.loyaltyService.IncrementReservationCount(r.Context(), username)
+			err = h.loyaltyService.IncrementReservationCount(r.Context(), username, reservationUID)
 		}
 
 		if err != nil {
@@ -113,7 +119,13 @@ func (h *Handler) Increment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.loyaltyService.IncrementReservationCount(r.Context(), username); err != nil {
+	reservationUID, ok := decodeReservationUID(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.loyaltyService.IncrementReservationCount(r.Context(), username, reservationUID); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -121,3 +133,16 @@ func (h *Handler) Increment(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// decodeReservationUID reads the {"reservationUid": "..."} body of counter
+// updates. The uid keys the event of the update and is required; a missing
+// body or uid is reported as not ok.
+func decodeReservationUID(r *http.Request) (string, bool) {
+	var body struct {
+		ReservationUID string `json:"reservationUid"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return "", false
+	}
+	return body.ReservationUID, body.ReservationUID != ""
+}
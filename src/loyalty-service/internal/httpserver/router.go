@@ -14,5 +14,5 @@ func NewRouter(s *service.LoyaltyService) http.Handler {
 	mux.HandleFunc("/manage/health", h.Health)
 	mux.HandleFunc("/internal/loyalty/", h.Loyalty)
 
-	return mux
+	return withDeadline(mux)
 }
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tier is a loyalty level granted from MinReservations reservations on.
+type Tier struct {
+	Status          string
+	MinReservations int
+	Discount        int
+}
+
+// Tiers is a tier ladder ordered by MinReservations, starting at zero.
+type Tiers []Tier
+
+func DefaultTiers() Tiers {
+	return Tiers{
+		{Status: "BRONZE", MinReservations: 0, Discount: 5},
+		{Status: "SILVER", MinReservations: 10, Discount: 7},
+		{Status: "GOLD", MinReservations: 20, Discount: 10},
+	}
+}
+
+// ParseTiers reads a ladder in the form "BRONZE:0:5,SILVER:10:7,GOLD:20:10",
+// where each entry is status:minReservations:discount.
+func ParseTiers(raw string) (Tiers, error) {
+	var tiers Tiers
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("tier %q: want status:minReservations:discount", entry)
+		}
+		min, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("tier %q: min reservations: %w", entry, err)
+		}
+		discount, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("tier %q: discount: %w", entry, err)
+		}
+		tiers = append(tiers, Tier{
+			Status:          strings.ToUpper(strings.TrimSpace(parts[0])),
+			MinReservations: min,
+			Discount:        discount,
+		})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinReservations < tiers[j].MinReservations
+	})
+
+	if err := tiers.Validate(); err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+func (t Tiers) Validate() error {
+	if len(t) == 0 {
+		return fmt.Errorf("no loyalty tiers configured")
+	}
+	if t[0].MinReservations != 0 {
+		return fmt.Errorf("lowest tier %s must start at 0 reservations", t[0].Status)
+	}
+
+	seen := make(map[string]bool, len(t))
+	for i, tier := range t {
+		if tier.Status == "" {
+			return fmt.Errorf("tier without status")
+		}
+		if seen[tier.Status] {
+			return fmt.Errorf("duplicate tier %s", tier.Status)
+		}
+		seen[tier.Status] = true
+
+		if tier.Discount < 0 || tier.Discount > 100 {
+			return fmt.Errorf("tier %s: discount %d out of range", tier.Status, tier.Discount)
+		}
+		if i > 0 && tier.MinReservations == t[i-1].MinReservations {
+			return fmt.Errorf("tiers %s and %s share threshold %d", t[i-1].Status, tier.Status, tier.MinReservations)
+		}
+	}
+	return nil
+}
+
+// Base is the tier every new member starts in.
+func (t Tiers) Base() Tier {
+	return t[0]
+}
+
+// For returns the highest tier reached with the given reservation count.
+func (t Tiers) For(reservationCount int) Tier {
+	result := t[0]
+	for _, tier := range t {
+		if reservationCount >= tier.MinReservations {
+			result = tier
+		}
+	}
+	return result
+}
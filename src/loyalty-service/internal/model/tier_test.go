@@ -0,0 +1,34 @@
+package model
+
+import "testing"
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := ParseTiers("gold:20:10, BRONZE:0:5,SILVER:10:7")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for count, want := range map[int]string{0: "BRONZE", 9: "BRONZE", 10: "SILVER", 19: "SILVER", 20: "GOLD", 26: "GOLD"} {
+		if got := tiers.For(count).Status; got != want {
+			t.Fatalf("count %d: expected %s, got %s", count, want, got)
+		}
+	}
+	if d := tiers.For(12).Discount; d != 7 {
+		t.Fatalf("expected SILVER discount 7, got %d", d)
+	}
+}
+
+func TestParseTiers_Invalid(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"SILVER:10:7",
+		"BRONZE:0:5,SILVER:0:7",
+		"BRONZE:0:5,BRONZE:10:7",
+		"BRONZE:0:150",
+		"BRONZE:zero:5",
+	} {
+		if _, err := ParseTiers(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
@@ -9,11 +9,12 @@ import (
 )
 
 type LoyaltyRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	tiers model.Tiers
 }
 
-func NewLoyaltyRepository(db *sql.DB) *LoyaltyRepository {
-	return &LoyaltyRepository{db: db}
+func NewLoyaltyRepository(db *sql.DB, tiers model.Tiers) *LoyaltyRepository {
+	return &LoyaltyRepository{db: db, tiers: tiers}
 }
 
 func (r *LoyaltyRepository) Ping(ctx context.Context) error {
@@ -24,14 +25,15 @@ func (r *LoyaltyRepository) GetLoyalty(ctx context.Context, username string) (mo
 	var resp model.LoyaltyResponse
 
 	err := r.db.QueryRowContext(ctx,
-		`SELECT status, discount, reservation_count FROM loyalties WHERE username = $1`,
+		`SELECT reservation_count FROM loyalties WHERE username = $1`,
 		username,
-	).Scan(&resp.Status, &resp.Discount, &resp.ReservationCount)
+	).Scan(&resp.ReservationCount)
 
 	if err == sql.ErrNoRows {
+		base := r.tiers.Base()
 		return model.LoyaltyResponse{
-			Status:           "Bronze",
-			Discount:         5,
+			Status:           base.Status,
+			Discount:         base.Discount,
 			ReservationCount: 0,
 		}, nil
 	}
@@ -40,32 +42,178 @@ func (r *LoyaltyRepository) GetLoyalty(ctx context.Context, username string) (mo
 		return model.LoyaltyResponse{}, fmt.Errorf("query loyalty: %w", err)
 	}
 
+	tier := r.tiers.For(resp.ReservationCount)
+	resp.Status = tier.Status
+	resp.Discount = tier.Discount
+
 	return resp, nil
 }
 
-func (r *LoyaltyRepository) IncrementReservationCount(ctx context.Context, username string) error {
-	_, err := r.db.ExecContext(ctx,
-		`UPDATE loyalties SET reservation_count = reservation_count + 1 WHERE username = $1`,
-		username,
+// derivedCount is the reservation count of loyalties row l as the events
+// tell it: the reservations counted before events were kept, plus the
+// reservations incremented and not canceled, minus the canceled ones that
+// were never incremented, which are taken from the former.
+const derivedCount = `GREATEST(l.legacy_count
+	+ (SELECT count(*) FROM loyalty_events i
+	   WHERE i.username = l.username AND i.event_type = 'INCREMENT'
+	     AND NOT EXISTS (SELECT 1 FROM loyalty_events d
+	                     WHERE d.reservation_uid = i.reservation_uid AND d.event_type = 'DECREMENT'))
+	- (SELECT count(*) FROM loyalty_events d
+	   WHERE d.username = l.username AND d.event_type = 'DECREMENT'
+	     AND NOT EXISTS (SELECT 1 FROM loyalty_events i
+	                     WHERE i.reservation_uid = d.reservation_uid AND i.event_type = 'INCREMENT')),
+	0)`
+
+// IncrementReservationCount records the reservation as counted, enrolling
+// the user in the base tier on the first one. Repeating it is a no-op, and a
+// reservation already canceled is not counted.
+func (r *LoyaltyRepository) IncrementReservationCount(ctx context.Context, username, reservationUID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin increment: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := recordEvent(ctx, tx, username, reservationUID, "INCREMENT"); err != nil {
+		return err
+	}
+
+	base := r.tiers.Base()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO loyalties (username, reservation_count, status, discount)
+		 VALUES ($1, 0, $2, $3)
+		 ON CONFLICT (username) DO NOTHING`,
+		username, base.Status, base.Discount,
 	)
 	if err != nil {
+		return fmt.Errorf("insert loyalty: %w", err)
+	}
+
+	if err := r.recount(ctx, tx, username); err != nil {
 		return fmt.Errorf("increment reservation_count: %w", err)
 	}
-	return nil
+	return tx.Commit()
+}
+
+// DecrementReservationCount records the reservation as canceled. Repeating
+// it is a no-op. A reservation counted before events were kept is taken
+// from the legacy part of the counter.
+func (r *LoyaltyRepository) DecrementReservationCount(ctx context.Context, username, reservationUID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin decrement: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := recordEvent(ctx, tx, username, reservationUID, "DECREMENT"); err != nil {
+		return err
+	}
+	if err := r.recount(ctx, tx, username); err != nil {
+		return fmt.Errorf("decrement reservation_count: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Reconcile recomputes from the events the counter of every user it does
+// not match, and returns how many were off.
+func (r *LoyaltyRepository) Reconcile(ctx context.Context) (int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT l.username FROM loyalties AS l WHERE l.reservation_count <> `+derivedCount,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("select drifted loyalties: %w", err)
+	}
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan loyalty: %w", err)
+		}
+		usernames = append(usernames, username)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	for _, username := range usernames {
+		if err := r.reconcileUser(ctx, username); err != nil {
+			return 0, err
+		}
+	}
+	return len(usernames), nil
 }
 
-func (r *LoyaltyRepository) DecrementReservationCount(ctx context.Context, username string) error {
-	_, err := r.db.ExecContext(ctx,
-		`UPDATE loyalties 
-		 SET reservation_count = CASE
-		 	 WHEN reservation_count > 0 THEN reservation_count - 1
-		 	 ELSE 0
-		 END
-		 WHERE username = $1`,
+func (r *LoyaltyRepository) reconcileUser(ctx context.Context, username string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin reconcile: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, username); err != nil {
+		return err
+	}
+	if err := r.recount(ctx, tx, username); err != nil {
+		return fmt.Errorf("reconcile reservation_count: %w", err)
+	}
+	return tx.Commit()
+}
+
+// recount sets the user's counter to the one derived from the events and
+// moves the user to the matching tier in the same transaction. A user
+// without a row is left untouched.
+func (r *LoyaltyRepository) recount(ctx context.Context, tx *sql.Tx, username string) error {
+	var count int
+	err := tx.QueryRowContext(ctx,
+		`UPDATE loyalties AS l SET reservation_count = `+derivedCount+`
+		 WHERE l.username = $1
+		 RETURNING l.reservation_count`,
 		username,
+	).Scan(&count)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tier := r.tiers.For(count)
+	_, err = tx.ExecContext(ctx,
+		`UPDATE loyalties SET status = $2, discount = $3 WHERE username = $1`,
+		username, tier.Status, tier.Discount,
 	)
 	if err != nil {
-		return fmt.Errorf("increment reservation_count: %w", err)
+		return fmt.Errorf("update tier: %w", err)
+	}
+	return nil
+}
+
+// recordEvent stores an event for the reservation. An event already stored
+// is left as it is, so the recount that follows changes nothing.
+func recordEvent(ctx context.Context, tx *sql.Tx, username, reservationUID, eventType string) error {
+	if err := lockUser(ctx, tx, username); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO loyalty_events (username, reservation_uid, event_type)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (reservation_uid, event_type) DO NOTHING`,
+		username, reservationUID, eventType,
+	)
+	if err != nil {
+		return fmt.Errorf("insert loyalty event: %w", err)
+	}
+	return nil
+}
+
+// lockUser serializes the transactions that change one user's counter, so
+// that each recount sees the events of the others.
+func lockUser(ctx context.Context, tx *sql.Tx, username string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, username); err != nil {
+		return fmt.Errorf("lock loyalty: %w", err)
 	}
 	return nil
 }
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/gazizov-ai/lab2-rsoi/src/loyalty-service/internal/model"
 	"github.com/gazizov-ai/lab2-rsoi/src/loyalty-service/internal/repository"
@@ -24,12 +23,16 @@ func (s *LoyaltyService) GetLoyalty(ctx context.Context, username string) (model
 	return s.repo.GetLoyalty(ctx, username)
 }
 
-func (s *LoyaltyService) IncrementReservationCount(ctx context.Context, username string) error {
-	fmt.Println("INC", username)
-	return s.repo.IncrementReservationCount(ctx, username)
+func (s *LoyaltyService) IncrementReservationCount(ctx context.Context, username, reservationUID string) error {
+	return s.repo.IncrementReservationCount(ctx, username, reservationUID)
 }
 
-func (s *LoyaltyService) DecrementReservationCount(ctx context.Context, username string) error {
-	fmt.Println("DEC", username)
-	return s.repo.DecrementReservationCount(ctx, username)
+func (s *LoyaltyService) DecrementReservationCount(ctx context.Context, username, reservationUID string) error {
+	return s.repo.DecrementReservationCount(ctx, username, reservationUID)
+}
+
+// Reconcile repairs the counters that no longer match the loyalty events and
+// returns how many there were.
+func (s *LoyaltyService) Reconcile(ctx context.Context) (int, error) {
+	return s.repo.Reconcile(ctx)
 }
@@ -0,0 +1,25 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// deadlineHeader carries the time in milliseconds the gateway still waits
+// for the answer.
+const deadlineHeader = "X-Request-Timeout-Ms"
+
+// withDeadline bounds the request context by the caller's deadline, so work
+// on a request the caller has given up on stops early.
+func withDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ms, err := strconv.Atoi(r.Header.Get(deadlineHeader)); err == nil && ms > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), time.Duration(ms)*time.Millisecond)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
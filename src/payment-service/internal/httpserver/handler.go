@@ -2,12 +2,16 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gazizov-ai/lab2-rsoi/src/payment-service/internal/service"
 )
 
+// maxBatchSize bounds the number of UIDs a batch lookup accepts.
+const maxBatchSize = 100
+
 type Handler struct {
 	svc *service.PaymentService
 }
@@ -36,8 +40,9 @@ func (h *Handler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body struct {
-		Username string `json:"username"`
-		Price    int    `json:"price"`
+		Username     string `json:"username"`
+		Price        int    `json:"price"`
+		OperationKey string `json:"operationKey"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -45,7 +50,11 @@ func (h *Handler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.svc.CreatePayment(r.Context(), body.Username, body.Price)
+	resp, err := h.svc.CreatePayment(r.Context(), body.Username, body.Price, body.OperationKey)
+	if errors.Is(err, service.ErrOperationKeyReused) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -71,6 +80,30 @@ func (h *Handler) GetPayment(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// GetPayments answers a batch lookup with the found payments keyed by UID.
+func (h *Handler) GetPayments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		UIDs []string `json:"uids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.UIDs) > maxBatchSize {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.svc.GetPayments(r.Context(), body.UIDs)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
 func (h *Handler) CancelPayment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -27,6 +27,7 @@ func NewRouter(s *service.PaymentService) http.Handler {
 	})
 
 	mux.HandleFunc("/internal/payments/byUser/", h.GetPaymentsByUser)
+	mux.HandleFunc("/internal/payments/batch", h.GetPayments)
 
-	return mux
+	return withDeadline(mux)
 }
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/lib/pq"
+
 	"github.com/gazizov-ai/lab2-rsoi/src/payment-service/internal/model"
 )
 
@@ -20,16 +22,43 @@ func (r *PaymentRepository) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
 }
 
-func (r *PaymentRepository) CreatePayment(ctx context.Context, payment model.PaymentResponse) error {
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO payments(payment_uid, username, status, price)
-		 VALUES ($1, $2, $3, $4)`,
-		payment.PaymentUID, payment.Username, payment.Status, payment.Price,
-	)
+// CreatePayment inserts the payment and returns the stored row. A payment
+// with the same non-empty operation key is returned unchanged instead of
+// inserting a new one; it is up to the caller to check that it matches.
+func (r *PaymentRepository) CreatePayment(ctx context.Context, payment model.PaymentResponse, operationKey string) (model.PaymentResponse, error) {
+	var p model.PaymentResponse
+
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO payments(payment_uid, username, status, price, operation_key)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		 ON CONFLICT (operation_key) DO NOTHING
+		 RETURNING payment_uid, username, status, price`,
+		payment.PaymentUID, payment.Username, payment.Status, payment.Price, operationKey,
+	).Scan(&p.PaymentUID, &p.Username, &p.Status, &p.Price)
+
+	if err == sql.ErrNoRows {
+		return r.GetPaymentByOperationKey(ctx, operationKey)
+	}
+	if err != nil {
+		return model.PaymentResponse{}, fmt.Errorf("insert payment: %w", err)
+	}
+	return p, nil
+}
+
+func (r *PaymentRepository) GetPaymentByOperationKey(ctx context.Context, operationKey string) (model.PaymentResponse, error) {
+	var p model.PaymentResponse
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT payment_uid, username, status, price
+		 FROM payments WHERE operation_key = $1`,
+		operationKey,
+	).Scan(&p.PaymentUID, &p.Username, &p.Status, &p.Price)
+
 	if err != nil {
-		return fmt.Errorf("insert payment: %w", err)
+		return model.PaymentResponse{}, fmt.Errorf("select payment by operation key: %w", err)
 	}
-	return nil
+
+	return p, nil
 }
 
 func (r *PaymentRepository) GetPayment(ctx context.Context, uid string) (model.PaymentResponse, error) {
@@ -89,3 +118,32 @@ func (r *PaymentRepository) GetPaymentsByUser(ctx context.Context, username stri
 
 	return result, rows.Err()
 }
+
+func (r *PaymentRepository) GetPaymentsByUIDs(ctx context.Context, uids []string) ([]model.PaymentResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT payment_uid, username, status, price
+		 FROM payments WHERE payment_uid = ANY($1::uuid[])`,
+		pq.Array(uids),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select payments by uid: %w", err)
+	}
+	defer rows.Close()
+
+	var result []model.PaymentResponse
+
+	for rows.Next() {
+		var p model.PaymentResponse
+		if err := rows.Scan(
+			&p.PaymentUID,
+			&p.Username,
+			&p.Status,
+			&p.Price,
+		); err != nil {
+			return nil, fmt.Errorf("scan payment: %w", err)
+		}
+		result = append(result, p)
+	}
+
+	return result, rows.Err()
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 
@@ -9,6 +10,10 @@ import (
 	"github.com/gazizov-ai/lab2-rsoi/src/payment-service/internal/repository"
 )
 
+// ErrOperationKeyReused is returned when an operation key already belongs to
+// a payment of another user or amount.
+var ErrOperationKeyReused = errors.New("operation key reused for a different payment")
+
 type PaymentService struct {
 	repo *repository.PaymentRepository
 }
@@ -21,7 +26,7 @@ func (s *PaymentService) Health(ctx context.Context) error {
 	return s.repo.Ping(ctx)
 }
 
-func (s *PaymentService) CreatePayment(ctx context.Context, username string, price int) (model.PaymentResponse, error) {
+func (s *PaymentService) CreatePayment(ctx context.Context, username string, price int, operationKey string) (model.PaymentResponse, error) {
 	p := model.PaymentResponse{
 		PaymentUID: uuid.New().String(),
 		Username:   username,
@@ -29,11 +34,14 @@ func (s *PaymentService) CreatePayment(ctx context.Context, username string, pri
 		Price:      price,
 	}
 
-	if err := s.repo.CreatePayment(ctx, p); err != nil {
+	stored, err := s.repo.CreatePayment(ctx, p, operationKey)
+	if err != nil {
 		return model.PaymentResponse{}, err
 	}
-
-	return p, nil
+	if stored.Username != username || stored.Price != price {
+		return model.PaymentResponse{}, ErrOperationKeyReused
+	}
+	return stored, nil
 }
 
 func (s *PaymentService) GetPayment(ctx context.Context, uid string) (model.PaymentResponse, error) {
@@ -47,3 +55,28 @@ func (s *PaymentService) CancelPayment(ctx context.Context, uid string) error {
 func (s *PaymentService) GetPaymentsByUser(ctx context.Context, username string) ([]model.PaymentResponse, error) {
 	return s.repo.GetPaymentsByUser(ctx, username)
 }
+
+// GetPayments returns the payments with the given UIDs keyed by UID. UIDs
+// that are malformed or match no payment are left out of the result.
+func (s *PaymentService) GetPayments(ctx context.Context, uids []string) (map[string]model.PaymentResponse, error) {
+	valid := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		if _, err := uuid.Parse(uid); err == nil {
+			valid = append(valid, uid)
+		}
+	}
+
+	result := make(map[string]model.PaymentResponse, len(valid))
+	if len(valid) == 0 {
+		return result, nil
+	}
+
+	payments, err := s.repo.GetPaymentsByUIDs(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range payments {
+		result[p.PaymentUID] = p
+	}
+	return result, nil
+}
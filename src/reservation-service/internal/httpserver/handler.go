@@ -2,7 +2,9 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -11,6 +13,9 @@ import (
 	"github.com/gazizov-ai/lab2-rsoi/src/reservation-service/internal/service"
 )
 
+// maxBatchSize bounds the number of UIDs a batch lookup accepts.
+const maxBatchSize = 100
+
 type Handler struct {
 	svc *service.ReservationService
 }
@@ -103,11 +108,22 @@ func (h *Handler) GetReservationsByUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	username := last(r.URL.Path)
-	res, err := h.svc.GetReservationsByUser(r.Context(), username)
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res, total, err := h.svc.GetReservationsByUser(r.Context(), username, filter)
+	if errors.Is(err, service.ErrInvalidFilter) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	_ = json.NewEncoder(w).Encode(res)
 }
 
@@ -131,10 +147,23 @@ func (h *Handler) ListHotels(w http.ResponseWriter, r *http.Request) {
 	}
 
 	q := r.URL.Query()
+	if raw := q.Get("uids"); raw != "" {
+		h.getHotels(w, r, strings.Split(raw, ","))
+		return
+	}
 	page := parseIntOrDefault(q.Get("page"), 1)
 	size := parseIntOrDefault(q.Get("size"), 10)
+	filter, err := parseHotelFilter(q)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-	resp, err := h.svc.ListHotels(r.Context(), page, size)
+	resp, err := h.svc.ListHotels(r.Context(), page, size, filter)
+	if errors.Is(err, service.ErrInvalidFilter) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -163,6 +192,79 @@ func (h *Handler) GetHotel(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(hh)
 }
 
+// getHotels answers a batch lookup with the found hotels keyed by UID.
+func (h *Handler) getHotels(w http.ResponseWriter, r *http.Request, uids []string) {
+	if len(uids) > maxBatchSize {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.svc.GetHotels(r.Context(), uids)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// parseHotelFilter reads a hotel filter from the query.
+func parseHotelFilter(q url.Values) (model.HotelFilter, error) {
+	f := model.HotelFilter{
+		Country: q.Get("country"),
+		City:    q.Get("city"),
+		Query:   q.Get("query"),
+		Sort:    q.Get("sort"),
+	}
+
+	for key, dst := range map[string]*int{"minStars": &f.MinStars, "minPrice": &f.MinPrice, "maxPrice": &f.MaxPrice} {
+		raw := q.Get(key)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return f, err
+		}
+		*dst = v
+	}
+	return f, nil
+}
+
+// parseFilter reads a reservation filter from the query. Dates are given as
+// YYYY-MM-DD and both ends of the range are inclusive.
+func parseFilter(q url.Values) (model.ReservationFilter, error) {
+	f := model.ReservationFilter{
+		Status: q.Get("status"),
+		When:   q.Get("when"),
+		Sort:   q.Get("sort"),
+	}
+
+	var err error
+	if raw := q.Get("from"); raw != "" {
+		if f.From, err = time.Parse("2006-01-02", raw); err != nil {
+			return f, err
+		}
+	}
+	if raw := q.Get("to"); raw != "" {
+		if f.To, err = time.Parse("2006-01-02", raw); err != nil {
+			return f, err
+		}
+		f.To = f.To.AddDate(0, 0, 1)
+	}
+	if raw := q.Get("page"); raw != "" {
+		if f.Page, err = strconv.Atoi(raw); err != nil {
+			return f, err
+		}
+	}
+	if raw := q.Get("size"); raw != "" {
+		if f.Size, err = strconv.Atoi(raw); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
 func last(path string) string {
 	parts := strings.Split(path, "/")
 	return parts[len(parts)-1]
@@ -37,5 +37,5 @@ func NewRouter(s *service.ReservationService) http.Handler {
 	mux.HandleFunc("/internal/hotels", h.ListHotels)
 	mux.HandleFunc("/internal/hotels/", h.GetHotel)
 
-	return mux
+	return withDeadline(mux)
 }
@@ -16,3 +16,16 @@ type HotelsPage struct {
 	TotalElements int     `json:"totalElements"`
 	Items         []Hotel `json:"items"`
 }
+
+// HotelFilter narrows and orders a hotel listing. Empty fields do not
+// filter. Query matches part of the name regardless of case. Sort names
+// price, stars or name, with a leading "-" for descending order.
+type HotelFilter struct {
+	Country  string
+	City     string
+	Query    string
+	MinStars int
+	MinPrice int
+	MaxPrice int
+	Sort     string
+}
@@ -20,3 +20,19 @@ type CreateReservationRequest struct {
 	EndDate    time.Time `json:"endDate"`
 	PaymentUID string    `json:"paymentUid"`
 }
+
+// ReservationFilter selects, orders and pages the reservations of a user.
+// Empty fields do not filter. From and To bound the start date, From
+// inclusive and To exclusive. When is "upcoming" for stays that have not
+// ended yet and "past" for the others. Sort names startDate, endDate or
+// status, with a leading "-" for descending order. A zero Size returns all
+// matches.
+type ReservationFilter struct {
+	Status string
+	From   time.Time
+	To     time.Time
+	When   string
+	Sort   string
+	Page   int
+	Size   int
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
 
 	"github.com/gazizov-ai/lab2-rsoi/src/reservation-service/internal/model"
 )
@@ -68,16 +71,52 @@ func (r *ReservationRepository) GetReservation(ctx context.Context, uid string)
 	return res, nil
 }
 
-func (r *ReservationRepository) GetReservationsByUser(ctx context.Context, username string) ([]model.Reservation, error) {
-	rows, err := r.db.QueryContext(ctx, `
+// GetReservationsByUser returns the page of a user's reservations selected
+// by f, together with the number of reservations matching f in total.
+func (r *ReservationRepository) GetReservationsByUser(ctx context.Context, username string, f model.ReservationFilter) ([]model.Reservation, int, error) {
+	where := []string{"r.username = $1"}
+	args := []interface{}{username}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Status != "" {
+		add("r.status = $%d", f.Status)
+	}
+	if !f.From.IsZero() {
+		add("r.start_date >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("r.start_date < $%d", f.To)
+	}
+	switch f.When {
+	case "upcoming":
+		where = append(where, "r.end_data >= now()")
+	case "past":
+		where = append(where, "r.end_data < now()")
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM reservations r WHERE `+cond, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count reservations: %w", err)
+	}
+
+	query := `
 		SELECT r.reservation_uid, r.username, h.hotel_uid, r.hotel_id, r.start_date, r.end_data, r.status, r.payment_uid
 		FROM reservations r
 		JOIN hotels h ON h.id = r.hotel_id
-		WHERE username = $1
-		ORDER BY start_date DESC
-	`, username)
+		WHERE ` + cond + `
+		ORDER BY ` + reservationOrder(f.Sort) + `, r.id`
+	if f.Size > 0 {
+		args = append(args, f.Size, (f.Page-1)*f.Size)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("list reservations: %w", err)
+		return nil, 0, fmt.Errorf("list reservations: %w", err)
 	}
 	defer rows.Close()
 
@@ -94,14 +133,35 @@ func (r *ReservationRepository) GetReservationsByUser(ctx context.Context, usern
 			&rsv.Status,
 			&rsv.PaymentUID,
 		); err != nil {
-			return nil, fmt.Errorf("scan reservation: %w", err)
+			return nil, 0, fmt.Errorf("scan reservation: %w", err)
 		}
 		res = append(res, rsv)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+	return res, total, nil
+}
+
+// reservationOrder turns a sort key of ReservationFilter into an ORDER BY
+// clause. Unknown keys fall back to the newest stays first.
+func reservationOrder(sort string) string {
+	dir := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		dir = "DESC"
+		sort = sort[1:]
+	}
+
+	switch sort {
+	case "startDate":
+		return "r.start_date " + dir
+	case "endDate":
+		return "r.end_data " + dir
+	case "status":
+		return "r.status " + dir
+	default:
+		return "r.start_date DESC"
 	}
-	return res, nil
 }
 
 func (r *ReservationRepository) CancelReservation(ctx context.Context, uid string) error {
@@ -131,7 +191,7 @@ func (r *ReservationRepository) GetHotelIDByUID(ctx context.Context, uid string)
 	return id, nil
 }
 
-func (r *ReservationRepository) ListHotels(ctx context.Context, page, size int) ([]model.Hotel, int, error) {
+func (r *ReservationRepository) ListHotels(ctx context.Context, page, size int, f model.HotelFilter) ([]model.Hotel, int, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -141,17 +201,49 @@ func (r *ReservationRepository) ListHotels(ctx context.Context, page, size int)
 
 	offset := (page - 1) * size
 
+	var where []string
+	var args []interface{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.Country != "" {
+		add("country = $%d", f.Country)
+	}
+	if f.City != "" {
+		add("city = $%d", f.City)
+	}
+	if f.Query != "" {
+		add("name ILIKE $%d", "%"+likeEscaper.Replace(f.Query)+"%")
+	}
+	if f.MinStars > 0 {
+		add("stars >= $%d", f.MinStars)
+	}
+	if f.MinPrice > 0 {
+		add("price >= $%d", f.MinPrice)
+	}
+	if f.MaxPrice > 0 {
+		add("price <= $%d", f.MaxPrice)
+	}
+
+	cond := ""
+	if len(where) > 0 {
+		cond = "WHERE " + strings.Join(where, " AND ")
+	}
+
 	var total int
-	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM hotels`).Scan(&total); err != nil {
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM hotels `+cond, args...).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("count hotels: %w", err)
 	}
 
+	args = append(args, size, offset)
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT hotel_uid, name, country, city, address, stars, price
 		FROM hotels
-		ORDER BY id
-		LIMIT $1 OFFSET $2
-	`, size, offset)
+		`+cond+`
+		ORDER BY `+hotelOrder(f.Sort)+`
+		`+fmt.Sprintf("LIMIT $%d OFFSET $%d", len(args)-1, len(args)), args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("select hotels: %w", err)
 	}
@@ -180,6 +272,27 @@ func (r *ReservationRepository) ListHotels(ctx context.Context, page, size int)
 	return items, total, nil
 }
 
+// likeEscaper keeps the wildcards of a search text from being read as part
+// of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// hotelOrder turns a sort key of HotelFilter into an ORDER BY clause. Hotels
+// are listed in the order they were added by default and on ties.
+func hotelOrder(sort string) string {
+	dir := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		dir = "DESC"
+		sort = sort[1:]
+	}
+
+	switch sort {
+	case "price", "stars", "name":
+		return sort + " " + dir + " NULLS LAST, id"
+	default:
+		return "id"
+	}
+}
+
 func (r *ReservationRepository) GetHotelByUID(ctx context.Context, hotelUID string) (model.Hotel, error) {
 	var h model.Hotel
 
@@ -206,3 +319,37 @@ func (r *ReservationRepository) GetHotelByUID(ctx context.Context, hotelUID stri
 
 	return h, nil
 }
+
+func (r *ReservationRepository) GetHotelsByUIDs(ctx context.Context, uids []string) ([]model.Hotel, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT hotel_uid, name, country, city, address, stars, price
+		FROM hotels
+		WHERE hotel_uid = ANY($1::uuid[])
+	`, pq.Array(uids))
+	if err != nil {
+		return nil, fmt.Errorf("select hotels by uid: %w", err)
+	}
+	defer rows.Close()
+
+	var items []model.Hotel
+	for rows.Next() {
+		var h model.Hotel
+		if err := rows.Scan(
+			&h.HotelUID,
+			&h.Name,
+			&h.Country,
+			&h.City,
+			&h.Address,
+			&h.Stars,
+			&h.Price,
+		); err != nil {
+			return nil, fmt.Errorf("scan hotel: %w", err)
+		}
+		items = append(items, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return items, nil
+}
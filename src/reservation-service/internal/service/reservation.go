@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 
@@ -10,6 +12,12 @@ import (
 	"github.com/gazizov-ai/lab2-rsoi/src/reservation-service/internal/repository"
 )
 
+// ErrInvalidFilter is returned for a reservation or hotel filter that
+// cannot be applied.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+const maxPageSize = 100
+
 type ReservationService struct {
 	repo *repository.ReservationRepository
 }
@@ -53,16 +61,53 @@ func (s *ReservationService) GetReservation(ctx context.Context, uid string) (mo
 	return s.repo.GetReservation(ctx, uid)
 }
 
-func (s *ReservationService) GetReservationsByUser(ctx context.Context, username string) ([]model.Reservation, error) {
-	return s.repo.GetReservationsByUser(ctx, username)
+// GetReservationsByUser returns the page of a user's reservations selected
+// by f and the number of matches in total.
+func (s *ReservationService) GetReservationsByUser(ctx context.Context, username string, f model.ReservationFilter) ([]model.Reservation, int, error) {
+	if err := validateFilter(f); err != nil {
+		return nil, 0, err
+	}
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	return s.repo.GetReservationsByUser(ctx, username, f)
+}
+
+func validateFilter(f model.ReservationFilter) error {
+	switch f.Status {
+	case "", "PAID", "CANCELED", "PENDING":
+	default:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, f.Status)
+	}
+	switch f.When {
+	case "", "upcoming", "past":
+	default:
+		return fmt.Errorf("%w: when must be upcoming or past", ErrInvalidFilter)
+	}
+	switch strings.TrimPrefix(f.Sort, "-") {
+	case "", "startDate", "endDate", "status":
+	default:
+		return fmt.Errorf("%w: cannot sort by %q", ErrInvalidFilter, f.Sort)
+	}
+	if f.Size < 0 || f.Size > maxPageSize {
+		return fmt.Errorf("%w: size must be between 0 and %d", ErrInvalidFilter, maxPageSize)
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+	return nil
 }
 
 func (s *ReservationService) CancelReservation(ctx context.Context, uid string) error {
 	return s.repo.CancelReservation(ctx, uid)
 }
 
-func (s *ReservationService) ListHotels(ctx context.Context, page, size int) (model.HotelsPage, error) {
-	items, total, err := s.repo.ListHotels(ctx, page, size)
+func (s *ReservationService) ListHotels(ctx context.Context, page, size int, f model.HotelFilter) (model.HotelsPage, error) {
+	if err := validateHotelFilter(f); err != nil {
+		return model.HotelsPage{}, err
+	}
+
+	items, total, err := s.repo.ListHotels(ctx, page, size, f)
 	if err != nil {
 		return model.HotelsPage{}, err
 	}
@@ -80,6 +125,49 @@ func (s *ReservationService) ListHotels(ctx context.Context, page, size int) (mo
 	}, nil
 }
 
+func validateHotelFilter(f model.HotelFilter) error {
+	switch strings.TrimPrefix(f.Sort, "-") {
+	case "", "price", "stars", "name":
+	default:
+		return fmt.Errorf("%w: cannot sort by %q", ErrInvalidFilter, f.Sort)
+	}
+	if f.MinStars < 0 || f.MinStars > 5 {
+		return fmt.Errorf("%w: minStars must be between 0 and 5", ErrInvalidFilter)
+	}
+	if f.MinPrice < 0 || f.MaxPrice < 0 {
+		return fmt.Errorf("%w: prices must not be negative", ErrInvalidFilter)
+	}
+	if f.MaxPrice > 0 && f.MinPrice > f.MaxPrice {
+		return fmt.Errorf("%w: minPrice must not be above maxPrice", ErrInvalidFilter)
+	}
+	return nil
+}
+
 func (s *ReservationService) GetHotel(ctx context.Context, hotelUID string) (model.Hotel, error) {
 	return s.repo.GetHotelByUID(ctx, hotelUID)
 }
+
+// GetHotels returns the hotels with the given UIDs keyed by UID. UIDs that
+// are malformed or match no hotel are left out of the result.
+func (s *ReservationService) GetHotels(ctx context.Context, uids []string) (map[string]model.Hotel, error) {
+	valid := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		if _, err := uuid.Parse(uid); err == nil {
+			valid = append(valid, uid)
+		}
+	}
+
+	result := make(map[string]model.Hotel, len(valid))
+	if len(valid) == 0 {
+		return result, nil
+	}
+
+	hotels, err := s.repo.GetHotelsByUIDs(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hotels {
+		result[h.HotelUID] = h
+	}
+	return result, nil
+}